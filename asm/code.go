@@ -3,6 +3,8 @@ package asm
 import (
 	"fmt"
 	"os"
+
+	"github.com/erazemk/sicsim/debuginfo"
 )
 
 type Code struct {
@@ -10,8 +12,10 @@ type Code struct {
 	startaddr    int
 	lc           int
 	length       int
+	line         int // Number of parsed lines
 	brelative    bool
 	pcstartaddr  int
+	pcset        bool // Start address was set by the first instruction
 	instructions []Node
 	symtab       map[string]interface{}
 }
@@ -111,3 +115,123 @@ func (c *Code) PrettyPrint() {
 	}
 	fmt.Println("--------------------")
 }
+
+// DebugInfo returns the symbols, source lines and layout of the code assembled from source
+func (c *Code) DebugInfo(source string) *debuginfo.Info {
+	info := &debuginfo.Info{
+		Program:  c.name,
+		Source:   source,
+		Start:    c.startaddr,
+		Entry:    c.pcstartaddr,
+		Length:   c.length,
+		Symbols:  []debuginfo.Symbol{},
+		Lines:    []debuginfo.Line{},
+		Sections: []debuginfo.Section{{Name: c.name, Start: c.startaddr, Length: c.length}},
+		Blocks:   []debuginfo.Block{},
+		Data:     []debuginfo.Data{},
+	}
+
+	equs := make(map[string]bool)
+
+	for _, node := range c.instructions {
+		if node.mnemonic == "EQU" {
+			equs[node.label] = true
+		}
+
+		if node.length == 0 {
+			continue
+		}
+
+		info.Lines = append(info.Lines, debuginfo.Line{
+			Addr:   node.lc,
+			Length: node.length,
+			Line:   node.line,
+			Code:   inSlice(node.mnemonic, Instructions),
+		})
+
+		// Consecutive nodes form a block, until ORG moves the location counter
+		if n := len(info.Blocks); n > 0 && info.Blocks[n-1].Start+info.Blocks[n-1].Length == node.lc {
+			info.Blocks[n-1].Length += node.length
+		} else {
+			info.Blocks = append(info.Blocks, debuginfo.Block{Start: node.lc, Length: node.length})
+		}
+
+		if node.label != "" && inSlice(node.mnemonic, StorageDirectives) {
+			info.Data = append(info.Data, debuginfo.Data{Name: node.label, Addr: node.lc, Size: node.length, Type: node.mnemonic})
+		}
+	}
+
+	for name := range c.symtab {
+		value, ok := c.symbolValue(name)
+		if !ok {
+			continue
+		}
+
+		kind := debuginfo.KindLabel
+		if equs[name] {
+			kind = debuginfo.KindEqu
+		}
+
+		info.Symbols = append(info.Symbols, debuginfo.Symbol{Name: name, Value: value, Kind: kind})
+	}
+
+	info.Sort()
+	return info
+}
+
+// CreateDebugInfo writes the debug info of the code assembled from source to the specified file
+func (c *Code) CreateDebugInfo(name, source string) error {
+	if err := c.DebugInfo(source).Write(name); err != nil {
+		return err
+	}
+
+	if debug {
+		fmt.Printf("Wrote debug info '%s'\n", name)
+	}
+
+	return nil
+}
+
+// AssembleFile assembles the source file src to the object file obj and writes its debug info next
+// to it. Errors, including panics of the assembler on invalid operands, are returned.
+func AssembleFile(src, obj string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to assemble %s: %v", src, r)
+		}
+	}()
+
+	code := NewCode()
+
+	if err := code.ParseFile(src); err != nil {
+		return fmt.Errorf("failed to assemble %s: %w", src, err)
+	}
+
+	code.ResolveSymbols()
+
+	if err := code.CreateObjectFile(obj); err != nil {
+		return fmt.Errorf("failed to assemble %s: %w", src, err)
+	}
+
+	if err := code.CreateDebugInfo(debuginfo.Path(obj), src); err != nil {
+		return fmt.Errorf("failed to assemble %s: %w", src, err)
+	}
+
+	return nil
+}
+
+// symbolValue returns the value of a symbol, following EQU directives that refer to other symbols
+func (c *Code) symbolValue(name string) (int, bool) {
+	for i := 0; i <= len(c.symtab); i++ {
+		switch val := c.symtab[name].(type) {
+		case int:
+			return val, true
+		case string:
+			name = val
+		default:
+			return 0, false
+		}
+	}
+
+	return 0, false
+}
@@ -27,7 +27,7 @@ var InstructionF3m = []string{
 	"STA", "STB", "STCH", "STF", "STL", "STS", "STT", "STX", // Store
 	"ADD", "AND", "COMP", "DIV", "SUB", "MUL", "OR", // Math
 	"ADDF", "COMPF", "DIVF", "SUBF", "MULF", // Float
-	"J", "JEQ", "JGT", "GLT", "JSUB", // Jump
+	"J", "JEQ", "JGT", "JLT", "JSUB", // Jump
 	"RD", "TD", "WD", // Device I/O
 	"SSK", // Other
 }
@@ -17,6 +17,7 @@ type Node struct {
 	extended  int
 	brelative bool
 	lc        int
+	line      int // Source line number
 }
 
 func NewNode(command []string, lc int, brelative bool) Node {
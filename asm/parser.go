@@ -3,12 +3,11 @@ package asm
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
 
-var isPCset bool
-
 // ParseFile reads the contents of the provided file and sends each line to ParseLine
 func (c *Code) ParseFile(path string) error {
 	file, err := os.Open(path)
@@ -18,18 +17,31 @@ func (c *Code) ParseFile(path string) error {
 
 	defer file.Close()
 
-	for sc := bufio.NewScanner(file); sc.Scan(); {
+	return c.Parse(file)
+}
+
+// Parse reads source code from r and sends each line to ParseLine
+func (c *Code) Parse(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+
+	for sc.Scan() {
 		if err := c.ParseLine(sc.Text()); err != nil {
 			return fmt.Errorf("failed to parse line: %w", err)
 		}
 	}
 
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read code: %w", err)
+	}
+
 	c.length = c.lc
 	return nil
 }
 
 // ParseLine parses a provided line and sets the code's attributes accordingly
 func (c *Code) ParseLine(line string) error {
+	c.line++
+
 	// Remove comments (only parse line until comment)
 	if strings.ContainsRune(line, '.') {
 		line = line[:strings.IndexRune(line, '.')]
@@ -47,6 +59,7 @@ func (c *Code) ParseLine(line string) error {
 	}
 
 	node := NewNode(command, c.lc, c.brelative)
+	node.line = c.line
 
 	// Check if label already exists in symtab
 	if node.label != "" {
@@ -91,9 +104,9 @@ func (c *Code) ParseLine(line string) error {
 	}
 
 	// Set PC start address based on where the first instruction is
-	if !isPCset && inSlice(node.mnemonic, Instructions) {
+	if !c.pcset && inSlice(node.mnemonic, Instructions) {
 		c.pcstartaddr = c.lc
-		isPCset = true
+		c.pcset = true
 
 		if debug {
 			fmt.Printf("Set PC start address to '%[1]d (%06[1]X)' at instruction '%[2]s'\n", c.pcstartaddr, node.mnemonic)
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/erazemk/sicsim/asm"
+	"github.com/erazemk/sicsim/debuginfo"
 	opt "github.com/pborman/getopt/v2"
 )
 
@@ -15,6 +16,7 @@ func main() {
 	lstFlag := opt.BoolLong("lst", 'l', "Pretty-print object and assembly code")
 	helpFlag := opt.BoolLong("help", 'h', "Show this text")
 	outputFlag := opt.StringLong("output", 'o', "", "Generated object file path", "/path/to/file.obj")
+	noDebugFlag := opt.BoolLong("no-debug-info", 0, "Don't write debug info (.dbg) next to the object file")
 	opt.SetParameters("/path/to/file.asm")
 	opt.Parse()
 
@@ -59,6 +61,7 @@ func main() {
 		outputFile = outputFile[:strings.LastIndex(outputFile, ".")]
 	}
 
+	debugFile := outputFile + debuginfo.Extension
 	outputFile += ".obj"
 
 	if *debugFlag {
@@ -70,6 +73,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	if !*noDebugFlag {
+		if err := code.CreateDebugInfo(debugFile, inputFile); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
 	if *lstFlag {
 		code.PrettyPrint()
 	}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/erazemk/sicsim/sim"
+	opt "github.com/pborman/getopt/v2"
+)
+
+func main() {
+	// Flags
+	helpFlag := opt.BoolLong("help", 'h', "Show this text")
+	tracksFlag := opt.IntLong("tracks", 't', sim.DefaultDiskGeometry.Tracks, "Number of tracks (create)", "n")
+	sectorsFlag := opt.IntLong("sectors", 's', sim.DefaultDiskGeometry.Sectors, "Sectors per track (create)", "n")
+	sizeFlag := opt.IntLong("sector-size", 'z', sim.DefaultDiskGeometry.SectorSize, "Sector size in bytes (create)", "n")
+	opt.SetParameters("command /path/to/image [args]")
+	opt.Parse()
+
+	if *helpFlag {
+		help()
+		os.Exit(0)
+	}
+
+	if opt.NArgs() < 2 {
+		fmt.Printf("No command or image provided!\n\n")
+		help()
+		os.Exit(1)
+	}
+
+	cmd, path, args := opt.Arg(0), opt.Arg(1), opt.Args()[2:]
+
+	if cmd == "create" {
+		geo := sim.DiskGeometry{Tracks: *tracksFlag, Sectors: *sectorsFlag, SectorSize: *sizeFlag}
+
+		if err := create(os.Stdout, path, geo); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	img, err := sim.OpenDiskImage(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	defer img.Close()
+
+	switch cmd {
+	case "info":
+		info(os.Stdout, path, img)
+	case "dump":
+		err = dump(img, args)
+	case "import":
+		err = importFile(img, args)
+	case "export":
+		err = export(img, args)
+	default:
+		err = fmt.Errorf("unknown command: %s", cmd)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		img.Close()
+		os.Exit(1)
+	}
+}
+
+func help() {
+	fmt.Println("Usage: sicdisk (-h) (-s n) (-t n) (-z n) command /path/to/image [args]")
+	fmt.Println()
+	fmt.Println("  -h, --help           Print this text")
+	fmt.Println("  -s, --sectors n      Sectors per track (create, default 18)")
+	fmt.Println("  -t, --tracks n       Number of tracks (create, default 80)")
+	fmt.Println("  -z, --sector-size n  Sector size in bytes (create, default 256)")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  create image                          Create an empty image")
+	fmt.Println("  info image                            Print the geometry of an image")
+	fmt.Println("  dump image track sector [count]       Print sectors as hex")
+	fmt.Println("  import image track sector file        Write a file to consecutive sectors")
+	fmt.Println("  export image track sector count file  Write consecutive sectors to a file (- for stdout)")
+}
+
+// create creates an empty image with the geometry geo
+func create(w io.Writer, path string, geo sim.DiskGeometry) error {
+	if err := sim.CreateDiskImage(path, geo); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Created %s: %s\n", path, geo)
+	return nil
+}
+
+// info prints the geometry of an image
+func info(w io.Writer, path string, img *sim.DiskImage) {
+	fmt.Fprintf(w, "%s: %s\n", path, img.Geometry)
+}
+
+// parseArgs parses n numeric arguments (decimal, or hex with the 0x prefix)
+func parseArgs(args []string, n int) ([]int, error) {
+	if len(args) < n {
+		return nil, fmt.Errorf("expected %d arguments, got %d", n, len(args))
+	}
+
+	vals := make([]int, n)
+
+	for i := range vals {
+		val, err := strconv.ParseInt(args[i], 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", args[i])
+		}
+
+		vals[i] = int(val)
+	}
+
+	return vals, nil
+}
+
+// sectors calls fn for count consecutive sectors, starting at track and sector
+func sectors(img *sim.DiskImage, track, sector, count int, fn func(track, sector int) error) error {
+	for i := 0; i < count; i++ {
+		if err := fn(track, sector); err != nil {
+			return err
+		}
+
+		if sector++; sector == img.Geometry.Sectors {
+			track, sector = track+1, 0
+		}
+	}
+
+	return nil
+}
+
+func dump(img *sim.DiskImage, args []string) error {
+	if len(args) == 2 {
+		args = append(args, "1")
+	}
+
+	vals, err := parseArgs(args, 3)
+	if err != nil {
+		return err
+	}
+
+	return sectors(img, vals[0], vals[1], vals[2], func(track, sector int) error {
+		data, err := img.ReadSector(track, sector)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Track %d, sector %d:\n", track, sector)
+
+		for off := 0; off < len(data); off += 16 {
+			end := off + 16
+			if end > len(data) {
+				end = len(data)
+			}
+
+			var hex, text strings.Builder
+
+			for _, b := range data[off:end] {
+				fmt.Fprintf(&hex, "%02X ", b)
+
+				if b >= 0x20 && b < 0x7F {
+					text.WriteByte(b)
+				} else {
+					text.WriteByte('.')
+				}
+			}
+
+			fmt.Printf("  %04X  %-48s %s\n", off, hex.String(), text.String())
+		}
+
+		return nil
+	})
+}
+
+func importFile(img *sim.DiskImage, args []string) error {
+	vals, err := parseArgs(args, 2)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 3 {
+		return fmt.Errorf("no input file provided")
+	}
+
+	data, err := os.ReadFile(args[2])
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	size := img.Geometry.SectorSize
+	count := (len(data) + size - 1) / size
+
+	err = sectors(img, vals[0], vals[1], count, func(track, sector int) error {
+		end := size
+		if end > len(data) {
+			end = len(data)
+		}
+
+		chunk := data[:end]
+		data = data[end:]
+		return img.WriteSector(track, sector, chunk)
+	})
+
+	if err == nil {
+		fmt.Printf("Wrote %d sectors\n", count)
+	}
+
+	return err
+}
+
+func export(img *sim.DiskImage, args []string) error {
+	vals, err := parseArgs(args, 3)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 4 {
+		return fmt.Errorf("no output file provided")
+	}
+
+	var out io.Writer = os.Stdout
+
+	if args[3] != "-" {
+		file, err := os.Create(args[3])
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+
+		defer file.Close()
+		out = file
+	}
+
+	return sectors(img, vals[0], vals[1], vals[2], func(track, sector int) error {
+		data, err := img.ReadSector(track, sector)
+		if err != nil {
+			return err
+		}
+
+		_, err = out.Write(data)
+		return err
+	})
+}
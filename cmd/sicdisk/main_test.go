@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// TestCreateInfo checks that info reports the geometry an image was created with
+func TestCreateInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	geo := sim.DiskGeometry{Tracks: 4, Sectors: 2, SectorSize: 128}
+
+	var out bytes.Buffer
+
+	if err := create(&out, path, geo); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := create(&out, path, sim.DiskGeometry{Tracks: 1, Sectors: 1}); err == nil {
+		t.Error("created an image with sectors of 0 bytes")
+	}
+
+	img, err := sim.OpenDiskImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer img.Close()
+
+	out.Reset()
+	info(&out, path, img)
+
+	if want := path + ": 4 tracks, 2 sectors per track, 128 bytes per sector (1024 bytes)\n"; out.String() != want {
+		t.Errorf("info printed %q, want %q", out.String(), want)
+	}
+}
+
+// TestSectors checks that consecutive sectors continue on the next track
+func TestSectors(t *testing.T) {
+	img := &sim.DiskImage{Geometry: sim.DiskGeometry{Tracks: 3, Sectors: 2, SectorSize: 1}}
+	var visited [][2]int
+
+	sectors(img, 0, 1, 3, func(track, sector int) error {
+		visited = append(visited, [2]int{track, sector})
+		return nil
+	})
+
+	want := [][2]int{{0, 1}, {1, 0}, {1, 1}}
+
+	if len(visited) != len(want) {
+		t.Fatalf("visited %v, want %v", visited, want)
+	}
+
+	for i := range want {
+		if visited[i] != want[i] {
+			t.Errorf("visited %v, want %v", visited, want)
+			break
+		}
+	}
+}
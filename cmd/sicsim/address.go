@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// parseRange parses a range of addresses from low up to, but not including, high
+func parseRange(m *sim.Machine, lowStr, highStr string) (int, int, error) {
+	low, err := m.ParseAddr(lowStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	high, err := m.ParseAddr(highStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if high < low {
+		return 0, 0, fmt.Errorf("invalid range: %s is below %s", highStr, lowStr)
+	}
+
+	return low, high, nil
+}
+
+// parseInt parses a decimal number, optionally prefixed with # like the values of script variables
+func parseInt(str string) (int, error) {
+	return strconv.Atoi(strings.TrimPrefix(str, "#"))
+}
+
+// parseValue parses a value given as a decimal number (optionally prefixed with #), a hex
+// number prefixed with 0x, a character in single quotes or a label
+func parseValue(m *sim.Machine, str string) (int, error) {
+	if len(str) == 3 && str[0] == '\'' && str[2] == '\'' {
+		return int(str[1]), nil
+	}
+
+	if strings.HasPrefix(str, "#") {
+		val, err := parseInt(str)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value: %s", str)
+		}
+
+		return val, nil
+	}
+
+	num, neg := strings.ToLower(str), false
+	if strings.HasPrefix(num, "-") {
+		num, neg = num[1:], true
+	}
+
+	var val int64
+	var err error
+
+	if strings.HasPrefix(num, "0x") {
+		val, err = strconv.ParseInt(num[2:], 16, 32)
+	} else {
+		val, err = strconv.ParseInt(num, 10, 32)
+	}
+
+	if err != nil {
+		if addr, ok := m.Symbols()[str]; ok {
+			return addr, nil
+		}
+
+		return 0, fmt.Errorf("invalid value: %s", str)
+	}
+
+	if neg {
+		val = -val
+	}
+
+	return int(val), nil
+}
+
+// parseByte parses a byte value, which may also be given as a signed byte
+func parseByte(m *sim.Machine, str string) (byte, error) {
+	val, err := parseValue(m, str)
+	if err != nil {
+		return 0, err
+	}
+
+	if val < -128 || val > 255 {
+		return 0, fmt.Errorf("not a valid byte: %s", str)
+	}
+
+	return byte(val), nil
+}
+
+// parseRegister parses a register given by its name or number
+func parseRegister(str string) (int, error) {
+	if no, ok := sim.RegisterNumber(str); ok {
+		return no, nil
+	}
+
+	no, err := parseInt(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid register: %s", str)
+	}
+
+	return no, nil
+}
+
+// location describes addr by its label and source line, if the debug info has them
+func location(m *sim.Machine, addr int) string {
+	var parts []string
+
+	if label := m.Label(addr); label != "" {
+		parts = append(parts, label)
+	}
+
+	if line, ok := m.SourceLine(addr); ok {
+		parts = append(parts, fmt.Sprintf("line %d", line))
+	}
+
+	return strings.Join(parts, ", ")
+}
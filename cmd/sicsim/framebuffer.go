@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// recorder captures frames of the memory-mapped framebuffer while the program runs
+type recorder struct {
+	fb    *sim.Framebuffer
+	scale int
+
+	dir      string         // Directory for the PNG image sequence, empty if not recording one
+	anim     *sim.Animation // Frames of the animated GIF, nil if not recording one
+	gifPath  string
+	captured int
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// mapFramebuffer maps the framebuffer selected on the command line
+func mapFramebuffer(m *sim.Machine, enabled bool, fbAddr, fbSize string, scale int) (*recorder, error) {
+	r := &recorder{scale: scale, done: make(chan struct{})}
+
+	if !enabled {
+		return r, nil
+	}
+
+	addr, err := strconv.ParseInt(fbAddr, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid framebuffer address: %s", fbAddr)
+	}
+
+	width, height, err := parseDimensions("framebuffer", fbSize)
+	if err != nil {
+		return nil, err
+	}
+
+	r.fb = sim.NewFramebuffer(width, height)
+	if err := m.MapRegion(int(addr), r.fb.Size(), r.fb); err != nil {
+		return nil, fmt.Errorf("failed to map framebuffer: %w", err)
+	}
+
+	return r, nil
+}
+
+// start captures a frame every interval in which the framebuffer changed, writing frames as a
+// numbered PNG image sequence into dir and collecting them for an animated GIF written to gifPath
+func (r *recorder) start(dir, gifPath string, interval time.Duration) error {
+	if r.fb == nil || (dir == "" && gifPath == "") {
+		return nil
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create frame directory: %w", err)
+		}
+
+		r.dir = dir
+	}
+
+	if gifPath != "" {
+		r.anim = sim.NewAnimation(interval)
+		r.gifPath = gifPath
+	}
+
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.captureChanged(); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return
+				}
+			case <-r.done:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// captureChanged captures a frame if the framebuffer changed since the last one
+func (r *recorder) captureChanged() error {
+	if !r.fb.Changed() {
+		return nil
+	}
+
+	frame := r.fb.Image(r.scale)
+	r.captured++
+
+	if r.anim != nil {
+		r.anim.Add(frame)
+	}
+
+	if r.dir != "" {
+		path := filepath.Join(r.dir, fmt.Sprintf("frame%05d.png", r.captured))
+		// Encode the captured frame, so the PNG matches the GIF even if the framebuffer changed meanwhile
+		err := writeFile(path, func(file *os.File) error {
+			if err := png.Encode(file, frame); err != nil {
+				return fmt.Errorf("failed to write PNG image: %w", err)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stop stops capturing frames, captures the last one and writes the animated GIF
+func (r *recorder) stop() error {
+	close(r.done)
+	r.wg.Wait()
+
+	if r.dir == "" && r.anim == nil {
+		return nil
+	}
+
+	if err := r.captureChanged(); err != nil {
+		return err
+	}
+
+	if r.anim != nil {
+		return writeFile(r.gifPath, func(file *os.File) error { return r.anim.WriteGIF(file) })
+	}
+
+	return nil
+}
+
+// snapshot writes the framebuffer to path as a PNG image
+func (r *recorder) snapshot(path string) error {
+	if r.fb == nil {
+		return fmt.Errorf("no framebuffer is mapped")
+	}
+
+	return writeFile(path, func(file *os.File) error { return r.fb.WritePNG(file, r.scale) })
+}
+
+// writeFile creates the file at path and writes it with write
+func writeFile(path string, write func(file *os.File) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
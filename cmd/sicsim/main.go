@@ -1,40 +1,86 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/erazemk/sicsim/sim"
 	"github.com/pborman/getopt/v2"
 )
 
+// Exit codes of non-REPL mode and scripts
+const (
+	exitHalt       = 0   // Program halted normally
+	exitError      = 1   // Invalid arguments or object file
+	exitFault      = 2   // Program faulted
+	exitStepLimit  = 3   // Step budget or instruction limit was used up
+	exitBreakpoint = 4   // Program reached a breakpoint
+	exitAssert     = 5   // Script assertion failed
+	exitCancelled  = 130 // Execution was interrupted
+)
+
 func main() {
 	// Flags
 	debugFlag := getopt.BoolLong("debug", 'd', "Enable debug output")
 	helpFlag := getopt.BoolLong("help", 'h', "Show this text")
 	interactiveFlag := getopt.BoolLong("non-repl", 'n', "Automatically run programs (non-REPL mode)")
+	haltAtFlag := getopt.ListLong("halt-at", 'a', "Halt before executing the instruction at addr (hex, label or :line)", "addr")
+	haltAfterFlag := getopt.IntLong("halt-after", 'l', 0, "Halt after executing n instructions", "n")
+	speedFlag := getopt.IntLong("speed", 's', 0, "Clock frequency in Hz (0 runs as fast as possible)", "hz")
+	breakFlag := getopt.ListLong("break", 'b', "Stop before executing the instruction at addr (hex, label or :line)", "addr")
+	overflowFlag := getopt.BoolLong("overflow-trap", 'o', "Fault on arithmetic overflow instead of wrapping")
+	profileFlag := getopt.StringLong("profile", 'p', "xe", "Machine profile (sic or xe)", "name")
+	memoryFlag := getopt.StringLong("memory", 'M', "", "Memory size in bytes (k and m suffixes are allowed)", "size")
+	devFlag := getopt.ListLong("dev", 'D', "Map a device to a file or socket (ID=path[:r|w|a|rw], ID=tcp:host:port or ID=missing)", "mapping")
+	devConfigFlag := getopt.StringLong("dev-config", 'c', "", "Read device mappings from file", "file")
+	screenFlag := getopt.BoolLong("screen", 0, "Map a text screen")
+	screenAddrFlag := getopt.StringLong("screen-addr", 0, "B800", "Screen address (hex)", "addr")
+	screenSizeFlag := getopt.StringLong("screen-size", 0, "80x25", "Screen size", "COLSxROWS")
+	screenDumpFlag := getopt.StringLong("screen-dump", 0, "", "Write the screen's text to file when the machine stops", "file")
+	keyboardFlag := getopt.BoolLong("keyboard", 0, "Map a keyboard register fed from stdin")
+	keyboardAddrFlag := getopt.StringLong("keyboard-addr", 0, "C000", "Keyboard address (hex)", "addr")
+	fbFlag := getopt.BoolLong("fb", 0, "Map a graphical framebuffer")
+	fbAddrFlag := getopt.StringLong("fb-addr", 0, "A000", "Framebuffer address (hex)", "addr")
+	fbSizeFlag := getopt.StringLong("fb-size", 0, "64x64", "Framebuffer size in pixels", "WIDTHxHEIGHT")
+	fbScaleFlag := getopt.IntLong("fb-scale", 0, 4, "Size of a framebuffer pixel in rendered images", "n")
+	fbPNGFlag := getopt.StringLong("fb-png", 0, "", "Write the framebuffer to a PNG image when the machine stops", "file")
+	fbFramesFlag := getopt.StringLong("fb-frames", 0, "", "Write changed frames as a PNG image sequence", "dir")
+	fbGIFFlag := getopt.StringLong("fb-gif", 0, "", "Write changed frames as an animated GIF", "file")
+	fbIntervalFlag := getopt.IntLong("fb-interval", 0, 100, "Time between captured frames in milliseconds", "ms")
+	profFlag := getopt.StringLong("prof", 0, "", "Profile the program and write the hot spots to file", "file")
+	profFoldedFlag := getopt.StringLong("prof-folded", 0, "", "Profile the program and write folded call stacks to file", "file")
+	profTopFlag := getopt.IntLong("prof-top", 0, 20, "Number of hot spots in the profile (0 lists all)", "n")
+	covFlag := getopt.StringLong("cov", 0, "", "Record coverage and write the annotated source to file", "file")
+	covHTMLFlag := getopt.StringLong("cov-html", 0, "", "Record coverage and write the annotated source as HTML to file", "file")
+	scriptFlag := getopt.StringLong("script", 'x', "", "Run debugger commands from file without prompts", "file")
+	jsonFlag := getopt.BoolLong("json", 0, "Print the result of each debugger command as a JSON object")
+	latencyFlag := getopt.ListLong("dev-latency", 'L', "Keep a device busy after each access (ID=n instructions or ID=nc cycles)", "latency")
 	getopt.Parse()
 
 	if *helpFlag {
 		help()
-		os.Exit(0)
+		os.Exit(exitHalt)
 	}
 
-	sim.SetDebug(*debugFlag)
-
 	objFile := getopt.Arg(0)
 	if objFile == "" {
 		fmt.Printf("No object file provided!\n\n")
 		help()
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
+	// Commands come from a script or piped stdin in batch mode
+	batch := *scriptFlag != "" || !isTerminal(os.Stdin)
+
 	// Clear screen if running in REPL mode (overwritten by debug mode)
-	if !*interactiveFlag {
+	if !*interactiveFlag && !batch {
 		scr := exec.Command("clear")
 		scr.Stdout = os.Stdout
 		scr.Run()
@@ -43,170 +89,447 @@ func main() {
 	// Create a new machine
 	var m sim.Machine
 	m.New()
-	m.SetInteractive(*interactiveFlag)
+
+	if *debugFlag {
+		m.SetDebug(true)
+	}
+
+	m.SetOverflowTrap(*overflowFlag)
+
+	profile, err := sim.ParseProfile(*profileFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	if *memoryFlag != "" {
+		size, err := parseSize(*memoryFlag)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitError)
+		}
+
+		profile = profile.WithMemSize(size)
+	}
+
+	if err := m.SetProfile(profile); err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	if err := mapDevices(&m, *devConfigFlag, *devFlag); err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	for _, spec := range *latencyFlag {
+		id, lat, err := sim.ParseDeviceLatency(spec)
+		if err == nil {
+			err = m.SetDeviceLatency(id, lat)
+		}
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitError)
+		}
+	}
+
+	term, err := mapTerminal(&m, *screenFlag || *screenDumpFlag != "", *screenAddrFlag, *screenSizeFlag,
+		*keyboardFlag, *keyboardAddrFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	rec, err := mapFramebuffer(&m, *fbFlag || *fbPNGFlag != "" || *fbFramesFlag != "" || *fbGIFFlag != "",
+		*fbAddrFlag, *fbSizeFlag, *fbScaleFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	if *fbIntervalFlag < 1 {
+		fmt.Printf("Invalid frame interval: %d ms\n", *fbIntervalFlag)
+		os.Exit(exitError)
+	}
+
+	if *profFlag != "" || *profFoldedFlag != "" {
+		m.SetProfiler(sim.NewProfiler())
+	}
 
 	if err := m.ParseObjFile(objFile); err != nil {
 		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	if *covFlag != "" || *covHTMLFlag != "" {
+		if m.DebugInfo() == nil {
+			fmt.Println("Coverage needs the debug info written by sicasm next to the object file")
+			os.Exit(exitError)
+		}
+
+		m.SetCoverage(sim.NewCoverage())
+	}
+
+	policy := m.HaltPolicy()
+	policy.MaxInstructions = *haltAfterFlag
+
+	for _, addr := range *haltAtFlag {
+		val, err := m.ParseAddr(addr)
+		if err != nil {
+			fmt.Printf("Invalid halt address: %s\n", addr)
+			os.Exit(exitError)
+		}
+
+		policy.Addresses = append(policy.Addresses, val)
+	}
+
+	if err := m.SetHaltPolicy(policy); err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	if err := m.SetSpeed(*speedFlag); err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+
+	for _, addr := range *breakFlag {
+		val, err := m.ParseAddr(addr)
+		if err == nil {
+			err = m.AddBreakpoint(val)
+		}
+
+		if err != nil {
+			fmt.Printf("Invalid breakpoint: %s\n", addr)
+			os.Exit(exitError)
+		}
 	}
 
 	if !*interactiveFlag {
-		header()
-		fmt.Println("(REPL mode)")
-		replHelp()
-		repl(m)
+		in := io.Reader(os.Stdin)
+
+		if *scriptFlag != "" {
+			file, err := os.Open(*scriptFlag)
+			if err != nil {
+				fmt.Printf("Failed to open script: %v\n", err)
+				os.Exit(exitError)
+			}
+
+			in = file
+		}
+
+		if !batch && !*jsonFlag {
+			header()
+			fmt.Println("(REPL mode)")
+			replHelp(os.Stdout)
+		}
+
+		s := newSession(&m, term, rec, *profTopFlag, in, !batch, *jsonFlag)
+		s.run()
+
+		m.Close()
+		dumpScreen(term, *screenDumpFlag)
+		snapshot(rec, *fbPNGFlag)
+		writeProfile(&m, *profFlag, *profFoldedFlag, *profTopFlag)
+		writeCoverage(&m, *covFlag, *covHTMLFlag)
+
+		if batch {
+			s.summary()
+			os.Exit(s.exitCode())
+		}
 	} else {
-		m.Start()
+		term.start(*screenFlag)
+
+		if err := rec.start(*fbFramesFlag, *fbGIFFlag, time.Duration(*fbIntervalFlag)*time.Millisecond); err != nil {
+			fmt.Println(err)
+			os.Exit(exitError)
+		}
+
+		res := run(&m, sim.Limits{})
+		term.stop()
+
+		if err := rec.stop(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		m.Close()
+		dumpScreen(term, *screenDumpFlag)
+		snapshot(rec, *fbPNGFlag)
+		writeProfile(&m, *profFlag, *profFoldedFlag, *profTopFlag)
+		writeCoverage(&m, *covFlag, *covHTMLFlag)
+
+		if exitCode(res) != exitHalt {
+			fmt.Fprintln(os.Stderr, describe(res))
+		}
+
+		os.Exit(exitCode(res))
 	}
 }
 
-// Runs the simulator in REPL mode
-func repl(m sim.Machine) {
-	sc := bufio.NewScanner(os.Stdin)
-	fmt.Print("> ")
+// dumpScreen writes the screen's text to path, if it is set
+func dumpScreen(term *terminal, path string) {
+	if path == "" {
+		return
+	}
 
-	for sc.Scan() {
-		switch text := strings.Split(sc.Text(), " "); text[0] {
-		case "regs", "r":
-			fmt.Println(m.Regs())
-		case "mem", "m":
-			low, err := strconv.Atoi(text[1])
+	if err := term.dump(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
 
-			if err != nil {
-				panic(err)
-			}
+// snapshot writes the framebuffer to a PNG image at path, if it is set
+func snapshot(rec *recorder, path string) {
+	if path == "" {
+		return
+	}
 
-			high, err := strconv.Atoi(text[2])
+	if err := rec.snapshot(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
 
-			if err != nil {
-				panic(err)
-			}
+// writeProfile writes the profiler's report and folded call stacks to their files, if they are set
+func writeProfile(m *sim.Machine, report, folded string, top int) {
+	p := m.Profiler()
+	if p == nil {
+		return
+	}
 
-			fmt.Println(m.Mem(low, high))
-		case "exec", "e":
-			if !m.Halted() {
-				m.Execute()
-			} else {
-				fmt.Println("Finished executing program, stop trying to break things")
-			}
-		case "step", "s":
-			if !m.Halted() {
-				m.Execute()
-				fmt.Println(m.Regs())
-			} else {
-				fmt.Println("Finished executing program, stop trying to break things")
-			}
-		case "word", "w":
-			addr, err := strconv.Atoi(text[1])
+	if report != "" {
+		if err := writeOutput(report, func(w io.Writer) error { return p.WriteReport(w, m, top) }); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
 
-			if err != nil {
-				panic(err)
-			}
+	if folded != "" {
+		if err := writeOutput(folded, func(w io.Writer) error { return p.WriteFolded(w, m) }); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
 
-			word, err := m.Word(addr)
+// writeCoverage writes the coverage reports to their files, if they are set
+func writeCoverage(m *sim.Machine, text, html string) {
+	c := m.Coverage()
+	if c == nil {
+		return
+	}
 
-			if err != nil {
-				panic(err)
-			}
+	if text != "" {
+		if err := writeOutput(text, func(w io.Writer) error { return c.WriteText(w, m) }); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
 
-			fmt.Printf("%02X\n", word)
-		case "byte", "b":
-			addr, err := strconv.Atoi(text[1])
+	if html != "" {
+		if err := writeOutput(html, func(w io.Writer) error { return c.WriteHTML(w, m) }); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
 
-			if err != nil {
-				panic(err)
-			}
+// writeOutput writes to the file at path with write, or to stdout if path is -
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
 
-			byt, err := m.Byte(addr)
+	return writeFile(path, func(file *os.File) error { return write(file) })
+}
 
-			if err != nil {
-				panic(err)
-			}
+// mapDevices maps devices from the config file and then from the command line, which replaces
+// config mappings of the same device
+func mapDevices(m *sim.Machine, config string, specs []string) error {
+	if config != "" {
+		mappings, err := sim.LoadDeviceConfig(config)
+		if err != nil {
+			return err
+		}
 
-			fmt.Printf("%02X\n", byt)
-		case "setreg", "sr":
-			no, err := strconv.Atoi(text[1])
+		if err := m.MapDevices(mappings); err != nil {
+			return err
+		}
+	}
 
-			if err != nil {
-				switch text[1] {
-				case "a", "A":
-					no = 0
-				case "x", "X":
-					no = 1
-				case "l", "L":
-					no = 2
-				case "b", "B":
-					no = 3
-				case "s", "S":
-					no = 4
-				case "t", "T":
-					no = 5
-				case "f", "F":
-					no = 6
-				case "pc", "PC":
-					no = 8
-				case "sw", "SW":
-					no = 9
-				default:
-					fmt.Printf("Invalid register: %s\n", text[2])
-					continue
-				}
-			}
+	var mappings []sim.DeviceMapping
 
-			val, err := strconv.Atoi(text[2])
+	for _, spec := range specs {
+		dm, err := sim.ParseDeviceMapping(spec)
+		if err != nil {
+			return err
+		}
 
-			if err != nil {
-				panic(err)
-			}
+		mappings = append(mappings, dm)
+	}
 
-			if err := m.SetReg(no, val); err != nil {
-				panic(err)
-			}
-		case "begin", "bt":
-			if !m.Halted() {
-				fmt.Println("Started automatic execution")
-				m.Start()
-			} else {
-				fmt.Println("Finished executing program, stop trying to break things")
-			}
-		case "end", "et":
-			if !m.Halted() {
-				fmt.Println("Stopped automatic execution")
-				m.Stop()
-			} else {
-				fmt.Println("Finished executing program, stop trying to break things")
-			}
-		default:
-			replHelp()
+	return m.MapDevices(mappings)
+}
+
+// parseSize parses a size in bytes with an optional k or m suffix
+func parseSize(str string) (int, error) {
+	mult := 1
+
+	switch strings.ToLower(str[len(str)-1:]) {
+	case "k":
+		mult = 1 << 10
+	case "m":
+		mult = 1 << 20
+	}
+
+	if mult != 1 {
+		str = str[:len(str)-1]
+	}
+
+	size, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", str)
+	}
+
+	return size * mult, nil
+}
+
+// run executes the program until it stops, or until the user interrupts it
+func run(m *sim.Machine, limits sim.Limits) sim.Result {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return m.Run(ctx, limits)
+}
+
+// exitCode maps the result of a run to the program's exit code
+func exitCode(res sim.Result) int {
+	switch res.Reason {
+	case sim.StopHalt:
+		// Running out of instructions is reported like running out of steps
+		if res.Halt == sim.HaltInstructionLimit {
+			return exitStepLimit
 		}
 
-		fmt.Print("> ")
+		return exitHalt
+	case sim.StopFault:
+		return exitFault
+	case sim.StopStepLimit:
+		return exitStepLimit
+	case sim.StopBreakpoint:
+		return exitBreakpoint
+	default:
+		return exitCancelled
 	}
 }
 
+// describe returns a short summary of the result of a run
+func describe(res sim.Result) string {
+	var reason string
+
+	switch res.Reason {
+	case sim.StopHalt:
+		reason = res.Halt.String()
+	case sim.StopFault:
+		reason = res.Fault.Error()
+	default:
+		reason = fmt.Sprintf("%s at 0x%06X", res.Reason, res.PC)
+	}
+
+	return fmt.Sprintf("-- Done (%s, %d instructions, %d cycles, %.0f instructions/s) --",
+		reason, res.Instructions, res.Cycles, res.InstructionsPerSecond())
+}
+
 func help() {
-	fmt.Println("Usage: sicsim (-dhn) /path/to/file.obj")
+	fmt.Println("Usage: sicsim (-dhno) (-a addr) (-b addr) (-c file) (-D mapping) (-l n) (-L latency) (-M size) (-p name) (-s hz) (-x file) /path/to/file.obj")
 	fmt.Println()
-	fmt.Println("  -d, --debug       Print debug info during execution")
-	fmt.Println("  -h, --help        Print this text")
-	fmt.Println("  -n, --non-repl    Automatically run programs (non-REPL mode)")
+	fmt.Println("  -a, --halt-at addr   Halt before executing the instruction at addr (hex, label or :line)")
+	fmt.Println("  -b, --break addr     Stop before executing the instruction at addr (hex, label or :line)")
+	fmt.Println("  -c, --dev-config f   Read device mappings from file f (one mapping per line)")
+	fmt.Println("  -d, --debug          Print debug info during execution")
+	fmt.Println("  -D, --dev mapping    Map a device, e.g. F1=input.txt:r, 05=out.txt:w or 06=missing")
+	fmt.Println("  -h, --help           Print this text")
+	fmt.Println("  -l, --halt-after n   Halt after executing n instructions, exiting with code 3")
+	fmt.Println("  -L, --dev-latency l  Keep a device busy after each RD/WD, e.g. 05=10 (instructions) or 05=500c (cycles)")
+	fmt.Println("  -M, --memory size    Memory size in bytes (k and m suffixes are allowed)")
+	fmt.Println("  -n, --non-repl       Automatically run programs (non-REPL mode)")
+	fmt.Println("  -o, --overflow-trap  Fault on arithmetic overflow instead of wrapping")
+	fmt.Println("  -p, --profile name   Machine profile: sic (32 KiB, SIC instructions) or xe (1 MiB, default)")
+	fmt.Println("  -s, --speed hz       Clock frequency in Hz (0 runs as fast as possible)")
+	fmt.Println("  -x, --script file    Run debugger commands from file without prompts (batch mode)")
+	fmt.Println("  --json               Print the result of each debugger command as a JSON object")
 	fmt.Println()
-}
-
-func replHelp() {
-	fmt.Println("Usage: [command] (options)")
+	fmt.Println("  Debugger commands piped to stdin also run in batch mode. Scripts support # comments,")
+	fmt.Println("  variables, if/while/repeat blocks and assert; see the REPL help for details.")
+	fmt.Println()
+	fmt.Println("Device mappings (ID=path[:mode], ID in hex):")
+	fmt.Println("  r    Read from path")
+	fmt.Println("  w    Write to path, truncating it first")
+	fmt.Println("  a    Append to path")
+	fmt.Println("  rw   Read from and append to path (default)")
+	fmt.Println("  The path 'missing' maps a device that is never ready.")
+	fmt.Println("  The path 'clock' maps a clock: WD selects cycles (00), instructions (01) or")
+	fmt.Println("  microseconds (02), then 6 RDs return the value, most significant byte first.")
+	fmt.Println("  Microseconds are simulated from the cycles at --speed, or 1 MHz when unthrottled.")
+	fmt.Println("  The path 'timer' maps the interval timer: 3 WDs set it in cycles, TD is ready once")
+	fmt.Println("  it expired with timer interrupts disabled and RD acknowledges the expiry.")
+	fmt.Println("  Unmapped devices 00-02 use stdin, stdout and stderr, the others use XX.dev.")
+	fmt.Println("  TD doesn't wait for stdin: it asks for one byte, which is read in the background.")
+	fmt.Println("  TD sets CC to LT if the device is ready, EQ if it is busy and GT at the end of input.")
+	fmt.Println("  Reading past the end of input and accessing a busy device raise a device fault.")
+	fmt.Println()
+	fmt.Println("Socket devices (ID=socket, used for both RD and WD):")
+	fmt.Println("  tcp:host:port           Connect to a TCP listener")
+	fmt.Println("  tcp-listen:[host]:port  Listen for a TCP peer")
+	fmt.Println("  unix:path               Connect to a Unix domain socket")
+	fmt.Println("  unix-listen:path        Listen for a peer on a Unix domain socket")
+	fmt.Println("  After RD, TD is ready while received data is waiting. Otherwise it is ready while")
+	fmt.Println("  a peer is connected, so WD won't wait for one.")
+	fmt.Println()
+	fmt.Println("Disk devices (ID=disk:image, create images with sicdisk):")
+	fmt.Println("  WD 01 tt tt ss  Seek to track tttt and sector ss")
+	fmt.Println("  WD 02           Read the sector, then RD its bytes")
+	fmt.Println("  WD 03           Write the sector, then WD its bytes")
+	fmt.Println("  WD 04           Read the geometry with 6 RDs")
+	fmt.Println()
+	fmt.Println("Memory-mapped screen and keyboard:")
+	fmt.Println("  --screen                 Map a text screen, drawn in the terminal while the program runs")
+	fmt.Println("  --screen-addr addr       Screen address (hex, default B800)")
+	fmt.Println("  --screen-size COLSxROWS  Screen size in characters (default 80x25)")
+	fmt.Println("  --screen-dump file       Write the screen's text to file (- for stdout) when the machine stops")
+	fmt.Println("  --keyboard               Map a keyboard register fed from stdin, read without line buffering")
+	fmt.Println("  --keyboard-addr addr     Keyboard address (hex, default C000)")
+	fmt.Println("  The keyboard register holds the oldest unacknowledged key, storing into it acknowledges the key.")
+	fmt.Println("  With --keyboard, device 00 shouldn't be used, as it also reads from stdin.")
+	fmt.Println()
+	fmt.Println("Memory-mapped framebuffer (one byte per pixel, colors in the form xxRRGGBB):")
+	fmt.Println("  --fb                     Map a framebuffer")
+	fmt.Println("  --fb-addr addr           Framebuffer address (hex, default A000)")
+	fmt.Println("  --fb-size WIDTHxHEIGHT   Framebuffer size in pixels (default 64x64)")
+	fmt.Println("  --fb-scale n             Size of a framebuffer pixel in rendered images (default 4)")
+	fmt.Println("  --fb-png file            Write the framebuffer to a PNG image when the machine stops")
+	fmt.Println("  --fb-frames dir          Write changed frames as a PNG image sequence (non-REPL mode)")
+	fmt.Println("  --fb-gif file            Write changed frames as an animated GIF (non-REPL mode)")
+	fmt.Println("  --fb-interval ms         Time between captured frames (default 100)")
+	fmt.Println()
+	fmt.Println("Profiling:")
+	fmt.Println("  --prof file         Write the hot spots by address, label and subroutine to file (- for stdout)")
+	fmt.Println("  --prof-folded file  Write cycles per call stack in the folded format of flame graph tools")
+	fmt.Println("  --prof-top n        Number of hot spots listed by address (default 20, 0 lists all)")
+	fmt.Println()
+	fmt.Println("Debug info:")
+	fmt.Println("  The file.dbg written by sicasm next to file.obj is loaded with the program, so addresses")
+	fmt.Println("  can be given as labels or source lines (:line) and disassembly shows source lines.")
+	fmt.Println("  Debug info that is malformed or doesn't match the object file's header is ignored.")
+	fmt.Println()
+	fmt.Println("Coverage (needs the debug info):")
+	fmt.Println("  --cov file       Write the source with execution counts and untaken jumps to file (- for stdout)")
+	fmt.Println("  --cov-html file  Write the same report as an HTML page")
 	fmt.Println()
-	fmt.Println("  Memory and registers:")
-	fmt.Println("    b, byte [addr]           Returns the byte at memory[addr]")
-	fmt.Println("    w, word [addr]           Returns the word at memory[addr]")
-	fmt.Println("    m, mem [low] [high]      Prints memory contents from low to high address")
-	fmt.Println("    r, regs                  Prints register values")
-	fmt.Println("    sr, setreg [no] [val]    Sets the register [no] to [val]")
+	fmt.Println("Exit codes (non-REPL and batch mode):")
+	fmt.Println("  0    Program halted")
+	fmt.Println("  1    Invalid arguments or object file")
+	fmt.Println("  2    Program faulted")
+	fmt.Println("  3    Instruction limit (--halt-after) reached")
+	fmt.Println("  4    Breakpoint reached")
+	fmt.Println("  5    Script assertion failed (1 if a script command failed, or the code given to exit)")
+	fmt.Println("  130  Interrupted")
 	fmt.Println()
-	fmt.Println("  Instructions:")
-	fmt.Println("    e, exec                  Executes the next instruction")
-	fmt.Println("    s, step                  Executes the next instruction and prints register values")
-	fmt.Println("    bt, begin                Starts automatically executing instructions")
-	fmt.Println("    et, end                  Stops automatically executing instructions")
 }
 
 func header() {
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// hexFile reports if path holds memory as hex text instead of raw bytes
+func hexFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".hex")
+}
+
+// saveMem writes the memory from low up to high to path, as hex text with 16 bytes per line if
+// path ends with .hex and as raw bytes otherwise
+func saveMem(m *sim.Machine, low, high int, path string) error {
+	data, err := m.ReadMem(low, high-low)
+	if err != nil {
+		return err
+	}
+
+	if hexFile(path) {
+		var sb strings.Builder
+
+		for i := 0; i < len(data); i += 16 {
+			end := i + 16
+			if end > len(data) {
+				end = len(data)
+			}
+
+			for j, val := range data[i:end] {
+				if j > 0 {
+					sb.WriteByte(' ')
+				}
+
+				fmt.Fprintf(&sb, "%02X", val)
+			}
+
+			sb.WriteByte('\n')
+		}
+
+		data = []byte(sb.String())
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save memory: %w", err)
+	}
+
+	return nil
+}
+
+// loadMem copies the contents of path to memory at addr, returning the number of bytes loaded.
+// Files ending with .hex hold hex bytes separated by whitespace, with # starting a comment.
+func loadMem(m *sim.Machine, addr int, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load memory: %w", err)
+	}
+
+	if hexFile(path) {
+		var digits strings.Builder
+
+		for _, line := range strings.Split(string(data), "\n") {
+			if i := strings.IndexByte(line, '#'); i >= 0 {
+				line = line[:i]
+			}
+
+			digits.WriteString(strings.Join(strings.Fields(line), ""))
+		}
+
+		if data, err = hex.DecodeString(digits.String()); err != nil {
+			return 0, fmt.Errorf("failed to load memory: invalid hex file: %w", err)
+		}
+	}
+
+	if err := m.WriteMem(addr, data); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// defaultPageSize is the number of rows printed before the pager pauses
+const defaultPageSize = 20
+
+// pager prints long views page by page, waiting for a line of input between pages
+type pager struct {
+	sc      *bufio.Scanner
+	size    int  // Rows per page, 0 prints everything at once
+	enabled bool // Pause between pages, off when commands don't come from or go to a terminal
+}
+
+// print prints rows to w, pausing after each page. Entering q stops printing.
+func (p *pager) print(w io.Writer, rows []string) {
+	for i, row := range rows {
+		if p.enabled && p.size > 0 && i > 0 && i%p.size == 0 {
+			fmt.Fprintf(w, "-- %d/%d rows, Enter for more, q to stop -- ", i, len(rows))
+
+			if !p.sc.Scan() || strings.TrimSpace(p.sc.Text()) == "q" {
+				return
+			}
+		}
+
+		fmt.Fprintln(w, row)
+	}
+}
+
+// parseCount parses the optional count at text[i], returning def if it's missing
+func parseCount(text []string, i, def int) (int, error) {
+	if len(text) <= i {
+		return def, nil
+	}
+
+	n, err := parseInt(text[i])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid count: %s", text[i])
+	}
+
+	return n, nil
+}
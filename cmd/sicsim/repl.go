@@ -0,0 +1,1081 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// maxIterations limits the iterations of a while loop, so a script can't loop forever
+const maxIterations = 1000000
+
+// session runs debugger commands typed in the REPL, piped to stdin or read from a script
+type session struct {
+	m       *sim.Machine
+	term    *terminal
+	rec     *recorder
+	profTop int
+
+	in          *bufio.Scanner
+	interactive bool // Print prompts and help, and page long views
+	json        bool // Print the result of each command as a JSON object
+	pg          *pager
+	out         io.Writer // Output of the session
+	w           io.Writer // Output of the current command
+
+	vars     map[string]int
+	lineNo   int // Number of the last line read
+	asserts  int // Number of executed assertions
+	failures int // Number of failed assertions
+	errors   int // Number of failed commands
+	exit     int // Exit code set by the exit command, -1 if it wasn't used
+}
+
+// statement is a command or a block of statements read from a line of input
+type statement struct {
+	line   int
+	text   string
+	block  string // if, while or repeat, empty for commands
+	body   []statement
+	orElse []statement // Body of the else branch of an if block
+}
+
+// commandResult is the JSON object printed for each command in JSON mode
+type commandResult struct {
+	Line    int      `json:"line"`
+	Command string   `json:"command"`
+	OK      bool     `json:"ok"`
+	Output  []string `json:"output"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// newSession returns a session reading commands from in
+func newSession(m *sim.Machine, term *terminal, rec *recorder, profTop int, in io.Reader, interactive, json bool) *session {
+	s := &session{
+		m:           m,
+		term:        term,
+		rec:         rec,
+		profTop:     profTop,
+		in:          bufio.NewScanner(in),
+		interactive: interactive,
+		json:        json,
+		out:         os.Stdout,
+		w:           os.Stdout,
+		vars:        make(map[string]int),
+		exit:        -1,
+	}
+
+	s.pg = &pager{sc: s.in, size: defaultPageSize, enabled: interactive && !json && isTerminal(os.Stdout)}
+	return s
+}
+
+// run reads and executes statements until the input ends or the exit command is used
+func (s *session) run() {
+	for {
+		text, ok := s.next("> ")
+		if !ok {
+			return
+		}
+
+		st, err := s.parse(text)
+		if err != nil {
+			s.report(s.lineNo, text, nil, err)
+			continue
+		}
+
+		if !s.execute(st) {
+			return
+		}
+	}
+}
+
+// exitCode returns the exit code of a script: the code given to exit, or else 5 if an assertion
+// failed and 1 if a command failed
+func (s *session) exitCode() int {
+	switch {
+	case s.exit >= 0:
+		return s.exit
+	case s.failures > 0:
+		return exitAssert
+	case s.errors > 0:
+		return exitError
+	}
+
+	return exitHalt
+}
+
+// summary prints the number of assertions and failed commands
+func (s *session) summary() {
+	if s.json {
+		data, _ := json.Marshal(map[string]int{"assertions": s.asserts, "failed": s.failures, "errors": s.errors})
+		fmt.Fprintf(s.out, "{\"summary\":%s}\n", data)
+		return
+	}
+
+	if s.asserts > 0 || s.errors > 0 {
+		fmt.Fprintf(s.out, "-- %d assertions, %d failed, %d errors --\n", s.asserts, s.failures, s.errors)
+	}
+}
+
+// next reads the next line of input, printing prompt first in interactive mode
+func (s *session) next(prompt string) (string, bool) {
+	if s.interactive && !s.json {
+		fmt.Fprint(s.out, prompt)
+	}
+
+	if !s.in.Scan() {
+		return "", false
+	}
+
+	s.lineNo++
+	return s.in.Text(), true
+}
+
+// parse parses the statement starting with text, reading the rest of a block from the input
+func (s *session) parse(text string) (statement, error) {
+	st := statement{line: s.lineNo, text: strings.TrimSpace(stripComment(text))}
+	fields := strings.Fields(st.text)
+
+	if len(fields) == 0 {
+		return st, nil
+	}
+
+	switch fields[0] {
+	case "if", "while", "repeat":
+		st.block, st.text = fields[0], restOf(st.text, 1)
+	case "else", "end":
+		return st, fmt.Errorf("%s without if, while or repeat", fields[0])
+	default:
+		return st, nil
+	}
+
+	if st.text == "" {
+		return st, fmt.Errorf("missing %s", map[string]string{"if": "condition", "while": "condition", "repeat": "count"}[st.block])
+	}
+
+	body := &st.body
+
+	for {
+		text, ok := s.next("... ")
+		if !ok {
+			return st, fmt.Errorf("%s on line %d is missing its end", st.block, st.line)
+		}
+
+		switch strings.Join(strings.Fields(stripComment(text)), " ") {
+		case "end":
+			return st, nil
+		case "else":
+			if st.block != "if" || body == &st.orElse {
+				return st, fmt.Errorf("unexpected else on line %d", s.lineNo)
+			}
+
+			body = &st.orElse
+			continue
+		}
+
+		inner, err := s.parse(text)
+		if err != nil {
+			return st, err
+		}
+
+		*body = append(*body, inner)
+	}
+}
+
+// execute executes a statement, returning false if the session should stop
+func (s *session) execute(st statement) bool {
+	switch st.block {
+	case "":
+		return s.command(st)
+	case "if":
+		ok, err := s.condition(st.text)
+		if err != nil {
+			s.report(st.line, "if "+st.text, nil, err)
+			return true
+		}
+
+		body := st.body
+		if !ok {
+			body = st.orElse
+		}
+
+		return s.executeAll(body)
+	case "while":
+		for i := 0; ; i++ {
+			ok, err := s.condition(st.text)
+			if err == nil && i >= maxIterations {
+				err = fmt.Errorf("loop didn't end after %d iterations", maxIterations)
+			}
+
+			if err != nil {
+				s.report(st.line, "while "+st.text, nil, err)
+				return true
+			}
+
+			if !ok {
+				return true
+			}
+
+			if !s.executeAll(st.body) {
+				return false
+			}
+		}
+	case "repeat":
+		n, err := s.expression(strings.Fields(s.substitute(st.text)))
+		if err != nil {
+			s.report(st.line, "repeat "+st.text, nil, err)
+			return true
+		}
+
+		for i := 0; i < n; i++ {
+			if !s.executeAll(st.body) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// executeAll executes statements in order, returning false if the session should stop
+func (s *session) executeAll(sts []statement) bool {
+	for _, st := range sts {
+		if !s.execute(st) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// command executes a single command and reports its output, returning false if the session should stop
+func (s *session) command(st statement) bool {
+	if st.text == "" {
+		return true
+	}
+
+	line := s.substitute(st.text)
+
+	// Commands that take text get variables as plain decimal numbers
+	switch strings.Fields(st.text)[0] {
+	case "print", "echo", "string", "str", "key", "k":
+		line = s.expand(st.text, "%d")
+	}
+
+	var buf bytes.Buffer
+	s.w = s.out
+
+	if s.json {
+		s.w = &buf
+	}
+
+	stop, err := s.dispatch(line, strings.Fields(line))
+	s.w = s.out
+
+	s.report(st.line, line, buf.Bytes(), err)
+	return !stop
+}
+
+// report prints the result of a command: its output in JSON mode and its error
+func (s *session) report(lineNo int, command string, output []byte, err error) {
+	if err != nil {
+		if _, ok := err.(assertionError); !ok {
+			s.errors++
+		}
+	}
+
+	if s.json {
+		res := commandResult{Line: lineNo, Command: strings.TrimSpace(command), OK: err == nil, Output: []string{}}
+		if text := strings.TrimRight(string(output), "\n"); text != "" {
+			res.Output = strings.Split(text, "\n")
+		}
+
+		if err != nil {
+			res.Error = err.Error()
+		}
+
+		data, _ := json.Marshal(res)
+		fmt.Fprintln(s.out, string(data))
+		return
+	}
+
+	if err == nil {
+		return
+	}
+
+	if _, ok := err.(assertionError); ok {
+		fmt.Fprintf(s.out, "FAIL line %d: %v\n", lineNo, err)
+	} else if s.interactive {
+		fmt.Fprintln(s.out, err)
+	} else {
+		fmt.Fprintf(s.out, "Error on line %d: %v\n", lineNo, err)
+	}
+}
+
+// assertionError is the error of a failed assert command
+type assertionError struct {
+	msg string
+}
+
+func (e assertionError) Error() string {
+	return e.msg
+}
+
+// variable matches references to variables in the form $name or ${name}
+var variable = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// substitute replaces references to variables in line with their values as decimal numbers
+// prefixed with #, which addresses, values and counts all read as decimal
+func (s *session) substitute(line string) string {
+	return s.expand(line, "#%d")
+}
+
+// expand replaces references to variables in line with their values, formatted with format.
+// Unknown variables are left as is.
+func (s *session) expand(line, format string) string {
+	return variable.ReplaceAllStringFunc(line, func(ref string) string {
+		name := strings.Trim(ref, "${}")
+		if val, ok := s.vars[name]; ok {
+			return fmt.Sprintf(format, val)
+		}
+
+		return ref
+	})
+}
+
+// stripComment removes a comment, which starts with # at the start of the line or after
+// whitespace and is followed by whitespace. A # inside double quotes doesn't start a comment.
+func stripComment(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return ""
+	}
+
+	quoted := false
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '#' && !quoted && i > 0 && (line[i-1] == ' ' || line[i-1] == '\t') &&
+			(i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t'):
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// operand evaluates the operand at the start of tokens, returning its value and the number of
+// tokens it used. Operands are registers (reg A), memory (word addr, byte addr), the counters
+// cycles and instructions, halted (1 if the machine halted) or values.
+func (s *session) operand(tokens []string) (int, int, error) {
+	if len(tokens) == 0 {
+		return 0, 0, fmt.Errorf("missing operand")
+	}
+
+	switch tokens[0] {
+	case "reg", "word", "byte":
+		if len(tokens) < 2 {
+			return 0, 0, fmt.Errorf("missing %s operand", tokens[0])
+		}
+
+		if tokens[0] == "reg" {
+			no, err := parseRegister(tokens[1])
+			if err != nil {
+				return 0, 0, err
+			}
+
+			val, err := s.m.Reg(no)
+			return val, 2, err
+		}
+
+		addr, err := s.m.ParseAddr(tokens[1])
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if tokens[0] == "word" {
+			val, err := s.m.Word(addr)
+			return val, 2, err
+		}
+
+		val, err := s.m.Byte(addr)
+		return int(val), 2, err
+	case "cycles":
+		return s.m.Cycles(), 1, nil
+	case "instructions":
+		return s.m.Instructions(), 1, nil
+	case "halted":
+		if s.m.Halted() {
+			return 1, 1, nil
+		}
+
+		return 0, 1, nil
+	}
+
+	if strings.HasPrefix(tokens[0], "$") {
+		return 0, 0, fmt.Errorf("unknown variable: %s", tokens[0])
+	}
+
+	val, err := parseValue(s.m, tokens[0])
+	return val, 1, err
+}
+
+// expression evaluates operands joined by +, -, *, / and %, from left to right
+func (s *session) expression(tokens []string) (int, error) {
+	val, n, err := s.operand(tokens)
+	if err != nil {
+		return 0, err
+	}
+
+	for tokens = tokens[n:]; len(tokens) > 0; tokens = tokens[n:] {
+		op := tokens[0]
+
+		rhs, used, err := s.operand(tokens[1:])
+		if err != nil {
+			return 0, err
+		}
+
+		n = used + 1
+
+		switch op {
+		case "+":
+			val += rhs
+		case "-":
+			val -= rhs
+		case "*":
+			val *= rhs
+		case "/", "%":
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+
+			if op == "/" {
+				val /= rhs
+			} else {
+				val %= rhs
+			}
+		default:
+			return 0, fmt.Errorf("invalid operator: %s", op)
+		}
+	}
+
+	return val, nil
+}
+
+// comparisons are the operators of conditions
+var comparisons = map[string]func(a, b int) bool{
+	"==": func(a, b int) bool { return a == b },
+	"!=": func(a, b int) bool { return a != b },
+	"<":  func(a, b int) bool { return a < b },
+	"<=": func(a, b int) bool { return a <= b },
+	">":  func(a, b int) bool { return a > b },
+	">=": func(a, b int) bool { return a >= b },
+}
+
+// compare evaluates a condition in the form expression op expression, or a single expression
+// that is true if it isn't 0. It returns the condition's result and the values it compared.
+func (s *session) compare(tokens []string) (bool, int, int, error) {
+	for i, token := range tokens {
+		cmp, ok := comparisons[token]
+		if !ok {
+			continue
+		}
+
+		lhs, err := s.expression(tokens[:i])
+		if err != nil {
+			return false, 0, 0, err
+		}
+
+		rhs, err := s.expression(tokens[i+1:])
+		if err != nil {
+			return false, 0, 0, err
+		}
+
+		return cmp(lhs, rhs), lhs, rhs, nil
+	}
+
+	val, err := s.expression(tokens)
+	return val != 0, val, 0, err
+}
+
+// condition evaluates the condition of an if or while block
+func (s *session) condition(text string) (bool, error) {
+	ok, _, _, err := s.compare(strings.Fields(s.substitute(text)))
+	return ok, err
+}
+
+// assert checks a condition, optionally followed by a message in double quotes
+func (s *session) assert(line string) error {
+	cond, msg := line, ""
+
+	if i := strings.IndexByte(line, '"'); i >= 0 {
+		cond, msg = line[:i], strings.TrimSpace(line[i:])
+
+		if unquoted, err := strconv.Unquote(msg); err == nil {
+			msg = unquoted
+		}
+	}
+
+	tokens := strings.Fields(cond)
+	if len(tokens) == 0 {
+		return fmt.Errorf("Usage: assert [condition] (\"message\")")
+	}
+
+	ok, lhs, rhs, err := s.compare(tokens)
+	if err != nil {
+		return err
+	}
+
+	s.asserts++
+
+	if ok {
+		if s.json {
+			fmt.Fprintln(s.w, "passed")
+		}
+
+		return nil
+	}
+
+	s.failures++
+
+	desc := fmt.Sprintf("%s (got %d", strings.Join(tokens, " "), lhs)
+	for _, token := range tokens {
+		if _, isCmp := comparisons[token]; isCmp {
+			desc += fmt.Sprintf(", expected %s %d", token, rhs)
+			break
+		}
+	}
+
+	desc += ")"
+
+	if msg != "" {
+		desc = msg + ": " + desc
+	}
+
+	return assertionError{msg: "assertion failed: " + desc}
+}
+
+// needArgs returns an error with a command's usage if it has less than n words
+func needArgs(text []string, n int, usage string) error {
+	if len(text) < n {
+		return fmt.Errorf("Usage: %s", usage)
+	}
+
+	return nil
+}
+
+// restOf returns line without its first n words
+func restOf(line string, n int) string {
+	for i := 0; i < n; i++ {
+		line = strings.TrimLeft(line, " \t")
+
+		if j := strings.IndexAny(line, " \t"); j >= 0 {
+			line = line[j:]
+		} else {
+			return ""
+		}
+	}
+
+	return strings.TrimLeft(line, " \t")
+}
+
+// dispatch executes the command in line, split into words in text. It returns true if the session should stop.
+func (s *session) dispatch(line string, text []string) (bool, error) {
+	m := s.m
+
+	switch text[0] {
+	case "regs", "r":
+		fmt.Fprintln(s.w, m.Regs())
+	case "mem", "m":
+		if err := needArgs(text, 2, "mem [low] (high)"); err != nil {
+			return false, err
+		}
+
+		low, err := m.ParseAddr(text[1])
+		high := low + 4*16
+
+		if err == nil && len(text) > 2 {
+			low, high, err = parseRange(m, text[1], text[2])
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		if high > m.MemSize() && len(text) == 2 {
+			high = m.MemSize()
+		}
+
+		rows, err := m.HexDump(low, high-low)
+		if err != nil {
+			return false, err
+		}
+
+		s.pg.print(s.w, rows)
+	case "words", "mw", "floats", "mf":
+		if err := needArgs(text, 2, text[0]+" [addr] (n)"); err != nil {
+			return false, err
+		}
+
+		addr, err := m.ParseAddr(text[1])
+		if err != nil {
+			return false, err
+		}
+
+		dump, def := m.WordDump, 8
+		if text[0] == "floats" || text[0] == "mf" {
+			dump, def = m.FloatDump, 4
+		}
+
+		n, err := parseCount(text, 2, def)
+		if err != nil {
+			return false, err
+		}
+
+		rows, err := dump(addr, n)
+		if err != nil {
+			return false, err
+		}
+
+		s.pg.print(s.w, rows)
+	case "labels", "l":
+		low, high := 0, m.MemSize()
+
+		if len(text) == 2 {
+			return false, fmt.Errorf("Usage: labels (low high)")
+		}
+
+		if len(text) > 2 {
+			var err error
+			if low, high, err = parseRange(m, text[1], text[2]); err != nil {
+				return false, err
+			}
+		}
+
+		s.pg.print(s.w, m.LabelDump(low, high))
+	case "page":
+		if len(text) < 2 {
+			fmt.Fprintf(s.w, "%d rows per page\n", s.pg.size)
+			break
+		}
+
+		n, err := parseInt(text[1])
+		if err != nil || n < 0 {
+			return false, fmt.Errorf("invalid page size: %s", text[1])
+		}
+
+		s.pg.size = n
+	case "exec", "e", "step", "s":
+		if !m.Resume() {
+			fmt.Fprintln(s.w, "Finished executing program, stop trying to break things")
+			break
+		}
+
+		if err := m.Execute(); err != nil {
+			return false, err
+		}
+
+		if text[0] == "step" || text[0] == "s" {
+			fmt.Fprintln(s.w, m.Regs())
+		}
+	case "speed", "hz":
+		if len(text) < 2 {
+			fmt.Fprintf(s.w, "%d Hz\n", m.Speed())
+			break
+		}
+
+		hz, err := parseInt(text[1])
+		if err != nil {
+			return false, fmt.Errorf("invalid frequency: %s", text[1])
+		}
+
+		if err := m.SetSpeed(hz); err != nil {
+			return false, err
+		}
+	case "cycles", "c":
+		fmt.Fprintf(s.w, "%d cycles (%d instructions)\n", m.Cycles(), m.Instructions())
+	case "break", "bp":
+		if len(text) < 2 {
+			for _, addr := range m.Breakpoints() {
+				fmt.Fprintf(s.w, "%06X  %s\n", addr, location(m, addr))
+			}
+
+			break
+		}
+
+		addr, err := m.ParseAddr(text[1])
+		if err == nil {
+			err = m.AddBreakpoint(addr)
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("invalid breakpoint: %s", text[1])
+		}
+	case "delete", "del":
+		if err := needArgs(text, 2, "delete [addr]"); err != nil {
+			return false, err
+		}
+
+		addr, err := m.ParseAddr(text[1])
+		if err != nil {
+			return false, fmt.Errorf("invalid breakpoint: %s", text[1])
+		}
+
+		m.RemoveBreakpoint(addr)
+	case "halt", "h":
+		fmt.Fprintln(s.w, m.HaltReason())
+	case "dis", "d":
+		addr := m.PC()
+
+		if len(text) > 1 {
+			val, err := m.ParseAddr(text[1])
+			if err != nil {
+				return false, err
+			}
+
+			addr = val
+		}
+
+		n, err := parseCount(text, 2, 10)
+		if err != nil {
+			return false, err
+		}
+
+		rows, err := m.DisasmDump(addr, n)
+		if err != nil {
+			return false, err
+		}
+
+		s.pg.print(s.w, rows)
+	case "screen", "scr":
+		if s.term.screen == nil {
+			return false, fmt.Errorf("no screen is mapped")
+		}
+
+		fmt.Fprint(s.w, s.term.screen.Text())
+	case "prof", "p":
+		if m.Profiler() == nil {
+			m.SetProfiler(sim.NewProfiler())
+			fmt.Fprintln(s.w, "Started profiling")
+			break
+		}
+
+		if err := m.Profiler().WriteReport(s.w, m, s.profTop); err != nil {
+			return false, err
+		}
+	case "fb":
+		if err := needArgs(text, 2, "fb [file]"); err != nil {
+			return false, err
+		}
+
+		if err := s.rec.snapshot(text[1]); err != nil {
+			return false, err
+		}
+	case "key", "k":
+		if s.term.keyboard == nil {
+			return false, fmt.Errorf("no keyboard is mapped")
+		}
+
+		for _, key := range []byte(restOf(line, 1)) {
+			s.term.keyboard.Press(key)
+		}
+	case "word", "w", "byte", "b":
+		if err := needArgs(text, 2, text[0]+" [addr]"); err != nil {
+			return false, err
+		}
+
+		addr, err := m.ParseAddr(text[1])
+		if err != nil {
+			return false, err
+		}
+
+		if text[0] == "byte" || text[0] == "b" {
+			byt, err := m.Byte(addr)
+			if err != nil {
+				return false, err
+			}
+
+			fmt.Fprintf(s.w, "%02X\n", byt)
+			break
+		}
+
+		word, err := m.Word(addr)
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Fprintf(s.w, "%06X (Dec: %d)\n", word&0xFFFFFF, word)
+	case "setreg", "sr":
+		if err := needArgs(text, 3, "setreg [no] [val]"); err != nil {
+			return false, err
+		}
+
+		no, err := parseRegister(text[1])
+		if err != nil {
+			return false, err
+		}
+
+		val, err := s.expression(text[2:])
+		if err != nil {
+			return false, err
+		}
+
+		if err := m.SetReg(no, val); err != nil {
+			return false, err
+		}
+	case "poke", "pk":
+		if err := needArgs(text, 3, "poke [addr] [byte]..."); err != nil {
+			return false, err
+		}
+
+		addr, err := m.ParseAddr(text[1])
+		if err != nil {
+			return false, err
+		}
+
+		data := make([]byte, 0, len(text)-2)
+
+		for _, str := range text[2:] {
+			val, err := parseByte(m, str)
+			if err != nil {
+				return false, err
+			}
+
+			data = append(data, val)
+		}
+
+		if err := m.WriteMem(addr, data); err != nil {
+			return false, err
+		}
+	case "setword", "sw":
+		if err := needArgs(text, 3, "setword [addr] [word]..."); err != nil {
+			return false, err
+		}
+
+		addr, err := m.ParseAddr(text[1])
+		if err != nil {
+			return false, err
+		}
+
+		data := make([]byte, 0, 3*(len(text)-2))
+
+		for _, str := range text[2:] {
+			val, err := parseValue(m, str)
+			if err == nil && (val < -0x800000 || val > 0xFFFFFF) {
+				err = fmt.Errorf("not a valid word: %s", str)
+			}
+
+			if err != nil {
+				return false, err
+			}
+
+			word := sim.NewWord(val).Bytes()
+			data = append(data, word[:]...)
+		}
+
+		if err := m.WriteMem(addr, data); err != nil {
+			return false, err
+		}
+	case "fill", "f":
+		if err := needArgs(text, 4, "fill [low] [high] [byte]"); err != nil {
+			return false, err
+		}
+
+		low, high, err := parseRange(m, text[1], text[2])
+		if err != nil {
+			return false, err
+		}
+
+		val, err := parseByte(m, text[3])
+		if err != nil {
+			return false, err
+		}
+
+		if err := m.FillMem(low, high-low, val); err != nil {
+			return false, err
+		}
+	case "string", "str":
+		if err := needArgs(text, 3, "string [addr] [text]"); err != nil {
+			return false, err
+		}
+
+		addr, err := m.ParseAddr(text[1])
+		if err != nil {
+			return false, err
+		}
+
+		str := restOf(line, 2)
+		if strings.HasPrefix(str, "\"") {
+			if str, err = strconv.Unquote(str); err != nil {
+				return false, fmt.Errorf("invalid quoted string: %s", restOf(line, 2))
+			}
+		}
+
+		if err := m.WriteMem(addr, []byte(str)); err != nil {
+			return false, err
+		}
+	case "save":
+		if err := needArgs(text, 4, "save [low] [high] [file]"); err != nil {
+			return false, err
+		}
+
+		low, high, err := parseRange(m, text[1], text[2])
+		if err == nil {
+			err = saveMem(m, low, high, text[3])
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Fprintf(s.w, "Saved %d bytes to %s\n", high-low, text[3])
+	case "load":
+		if err := needArgs(text, 3, "load [addr] [file]"); err != nil {
+			return false, err
+		}
+
+		addr, err := m.ParseAddr(text[1])
+		if err != nil {
+			return false, err
+		}
+
+		n, err := loadMem(m, addr, text[2])
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Fprintf(s.w, "Loaded %d bytes at %06X\n", n, addr)
+	case "begin", "bt", "run":
+		if m.Halted() && m.HaltReason().Final() {
+			fmt.Fprintln(s.w, "Finished executing program, stop trying to break things")
+			break
+		}
+
+		if s.interactive && !s.json {
+			fmt.Fprintln(s.w, "Started automatic execution (interrupt with Ctrl-C)")
+			defer fmt.Fprintln(s.w)
+		}
+
+		res := run(m, sim.Limits{})
+		fmt.Fprintln(s.w, describe(res))
+	case "set":
+		if err := needArgs(text, 3, "set [name] [expression]"); err != nil {
+			return false, err
+		}
+
+		name := strings.TrimPrefix(text[1], "$")
+		if variable.FindString("$"+name) != "$"+name {
+			return false, fmt.Errorf("invalid variable name: %s", text[1])
+		}
+
+		val, err := s.expression(text[2:])
+		if err != nil {
+			return false, err
+		}
+
+		s.vars[name] = val
+	case "print", "echo":
+		str := restOf(line, 1)
+		if unquoted, err := strconv.Unquote(str); err == nil {
+			str = unquoted
+		}
+
+		fmt.Fprintln(s.w, str)
+	case "assert":
+		return false, s.assert(restOf(line, 1))
+	case "exit", "quit", "q":
+		s.exit = 0
+
+		if len(text) > 1 {
+			code, err := parseInt(text[1])
+			if err != nil || code < 0 || code > 255 {
+				return false, fmt.Errorf("invalid exit code: %s", text[1])
+			}
+
+			s.exit = code
+		}
+
+		return true, nil
+	case "help", "?":
+		replHelp(s.w)
+	default:
+		if s.interactive && !s.json {
+			replHelp(s.w)
+			break
+		}
+
+		return false, fmt.Errorf("unknown command: %s", text[0])
+	}
+
+	return false, nil
+}
+
+func replHelp(w io.Writer) {
+	fmt.Fprintln(w, "Usage: [command] (options)")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Memory and registers:")
+	fmt.Fprintln(w, "    b, byte [addr]           Returns the byte at memory[addr]")
+	fmt.Fprintln(w, "    w, word [addr]           Returns the word at memory[addr]")
+	fmt.Fprintln(w, "    m, mem [low] (high)      Prints a hexdump from low up to, not including, high (default 64 bytes)")
+	fmt.Fprintln(w, "    mw, words [addr] (n)     Prints n words (default 8) as hex, unsigned and signed values")
+	fmt.Fprintln(w, "    mf, floats [addr] (n)    Prints n 48-bit floats (default 4)")
+	fmt.Fprintln(w, "    l, labels (low high)     Prints the labels and what they hold, optionally from low up to high")
+	fmt.Fprintln(w, "    r, regs                  Prints register values")
+	fmt.Fprintln(w, "    c, cycles                Prints the number of used machine cycles")
+	fmt.Fprintln(w, "    sr, setreg [no] [val]    Sets the register [no] (number or name) to [val]")
+	fmt.Fprintln(w, "    pk, poke [addr] [b]...   Sets the bytes at memory[addr] to [b]...")
+	fmt.Fprintln(w, "    sw, setword [addr] [w].. Sets the words at memory[addr] to [w]...")
+	fmt.Fprintln(w, "    f, fill [low] [high] [b] Sets the bytes from low up to high address to [b]")
+	fmt.Fprintln(w, "    str, string [addr] [txt] Writes the characters of txt (\"quoted\" for escapes) to memory[addr]")
+	fmt.Fprintln(w, "    save [low] [high] [file] Saves memory from low up to high address to file (.hex for hex text)")
+	fmt.Fprintln(w, "    load [addr] [file]       Loads file (.hex for hex text, otherwise raw bytes) to memory[addr]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "    page (n)                 Prints or sets the rows per page of long views (0 disables paging)")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "    Addresses are hex even without 0x (10 is 0x10), decimal with a # prefix (#16), labels or")
+	fmt.Fprintln(w, "    source lines (:line). Labels shadow hex numbers, so ADD is a label if there is one; 0x forces hex.")
+	fmt.Fprintln(w, "    Ranges exclude their high address: mem 100 110 prints the 16 bytes from 0x100 to 0x10F.")
+	fmt.Fprintln(w, "    Values are decimal (# is optional), hex with a 0x prefix, characters in single quotes or labels.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Instructions:")
+	fmt.Fprintln(w, "    e, exec                  Executes the next instruction")
+	fmt.Fprintln(w, "    s, step                  Executes the next instruction and prints register values")
+	fmt.Fprintln(w, "    bt, begin, run           Executes instructions until the program stops (interrupt with Ctrl-C)")
+	fmt.Fprintln(w, "    bp, break (addr)         Adds a breakpoint at addr or lists breakpoints")
+	fmt.Fprintln(w, "    del, delete [addr]       Removes the breakpoint at addr")
+	fmt.Fprintln(w, "    h, halt                  Prints the reason the machine halted")
+	fmt.Fprintln(w, "                             (exec, step and run resume unless it was a self-jump or the limit)")
+	fmt.Fprintln(w, "    hz, speed (hz)           Prints or sets the clock frequency (0 is unthrottled)")
+	fmt.Fprintln(w, "    d, dis (addr) (n)        Disassembles n instructions (default 10) at addr or PC")
+	fmt.Fprintln(w, "    p, prof                  Starts profiling or prints the hot spots")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Screen and keyboard:")
+	fmt.Fprintln(w, "    scr, screen              Prints the memory-mapped screen")
+	fmt.Fprintln(w, "    k, key [text]            Presses the keys of text on the memory-mapped keyboard")
+	fmt.Fprintln(w, "    fb [file]                Writes the framebuffer to a PNG image")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Scripting:")
+	fmt.Fprintln(w, "    set [name] [expr]        Sets the variable name, used as $name or ${name}")
+	fmt.Fprintln(w, "    print [text]             Prints text (\"quoted\" for escapes)")
+	fmt.Fprintln(w, "    assert [cond] (\"msg\")    Checks a condition, failed assertions set the exit code to 5")
+	fmt.Fprintln(w, "    if [cond] / else / end   Executes commands if the condition holds")
+	fmt.Fprintln(w, "    while [cond] / end       Executes commands while the condition holds")
+	fmt.Fprintln(w, "    repeat [n] / end         Executes commands n times")
+	fmt.Fprintln(w, "    q, quit, exit (code)     Stops the session, optionally with an exit code")
+	fmt.Fprintln(w, "    # comment                Ignored, also after a command")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "    Expressions combine values, reg [r], word [addr], byte [addr], cycles, instructions and")
+	fmt.Fprintln(w, "    halted with + - * / %, from left to right. Conditions compare two expressions with")
+	fmt.Fprintln(w, "    == != < <= > >=, or check that an expression isn't 0.")
+}
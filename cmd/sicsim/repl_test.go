@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// runScript runs script in batch mode on a new machine, returning the session and its output
+func runScript(t *testing.T, script string) (*session, string) {
+	t.Helper()
+
+	var m sim.Machine
+	var out bytes.Buffer
+
+	m.New()
+	m.SetSymbols(map[string]int{"DATA": 0x30, "ADD": 0x40})
+
+	s := newSession(&m, &terminal{}, nil, 0, strings.NewReader(script), false, false)
+	s.out, s.w = &out, &out
+	s.run()
+
+	return s, out.String()
+}
+
+// TestScriptVariables checks that variables are used as decimal numbers by every kind of argument
+func TestScriptVariables(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		output string
+	}{
+		{"byte address", "poke #16 42\nset a 16\nbyte $a", "2A\n"},
+		{"word address", "setword 0x20 7\nset p 32\nword ${p}", "000007 (Dec: 7)\n"},
+		{"address from register", "setreg PC 0x20\nsetword 0x20 9\nset p reg PC\nword $p", "000009 (Dec: 9)\n"},
+		{"label arithmetic", "poke DATA 1 2 3\nset p DATA + 2\nbyte $p", "03\n"},
+		{"value", "set v 10\nsetreg A $v\nregs", "A:  00000A (Dec: 10)"},
+		{"negative value", "set v -3\nsetreg A $v\nassert reg A == -3", ""},
+		{"count", "set n 2\nwords 0 $n", "000003"},
+		{"print", "set n 16\nprint \"n is $n\"", "n is 16\n"},
+		{"loop over addresses", "set i 0\nwhile $i < 4\npoke $i $i\nset i $i + 1\nend\nmem #0 #4", "00 01 02 03"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out := runScript(t, tt.script)
+
+			if s.errors > 0 || s.failures > 0 {
+				t.Fatalf("script failed:\n%s", out)
+			}
+
+			if !strings.Contains(out, tt.output) {
+				t.Errorf("output %q doesn't contain %q", out, tt.output)
+			}
+		})
+	}
+}
+
+// TestAddressSyntax checks the radix of addresses and values, and that labels shadow hex numbers
+func TestAddressSyntax(t *testing.T) {
+	script := `poke 10 1
+assert byte #16 == 1
+poke #10 2
+assert byte 0xA == 2
+poke ADD 3
+assert byte 40 == 3
+poke 0xADD 4
+assert byte #2781 == 4
+poke 20 0x10 #17 'A'
+assert word 20 == 0x101141
+fill 30 33 9
+assert byte 32 == 9
+assert byte 33 == 0`
+
+	if s, out := runScript(t, script); s.errors > 0 || s.failures > 0 {
+		t.Errorf("script failed:\n%s", out)
+	}
+}
+
+// TestScriptAssert checks the exit codes of failed assertions and commands
+func TestScriptAssert(t *testing.T) {
+	tests := []struct {
+		script string
+		code   int
+	}{
+		{"poke 10 5\nassert byte 10 == 5", exitHalt},
+		{"poke 10 5\nassert byte 10 == 6 \"wrong\"", exitAssert},
+		{"bogus", exitError},
+		{"assert 1 == 2\nexit 7", 7},
+	}
+
+	for _, tt := range tests {
+		if s, out := runScript(t, tt.script); s.exitCode() != tt.code {
+			t.Errorf("script %q exited with %d, want %d:\n%s", tt.script, s.exitCode(), tt.code, out)
+		}
+	}
+}
+
+// TestPager checks that the pager prompts on its writer between pages and stops on q
+func TestPager(t *testing.T) {
+	rows := []string{"1", "2", "3", "4", "5"}
+
+	tests := []struct {
+		input   string
+		enabled bool
+		want    string
+	}{
+		{"\n\n", true, "1\n2\n-- 2/5 rows, Enter for more, q to stop -- 3\n4\n-- 4/5 rows, Enter for more, q to stop -- 5\n"},
+		{"q\n", true, "1\n2\n-- 2/5 rows, Enter for more, q to stop -- "},
+		{"", true, "1\n2\n-- 2/5 rows, Enter for more, q to stop -- "},
+		{"", false, "1\n2\n3\n4\n5\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		pg := &pager{sc: bufio.NewScanner(strings.NewReader(tt.input)), size: 2, enabled: tt.enabled}
+		pg.print(&out, rows)
+
+		if out.String() != tt.want {
+			t.Errorf("input %q: printed %q, want %q", tt.input, out.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// refreshInterval is how often the screen is redrawn while it changes
+const refreshInterval = 50 * time.Millisecond
+
+// terminal connects the memory-mapped screen and keyboard to the terminal
+type terminal struct {
+	screen   *sim.TextScreen
+	keyboard *sim.Keyboard
+	live     bool   // Redraw the screen while the program runs
+	stty     string // Terminal settings to restore, empty if they weren't changed
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// mapTerminal maps the screen and keyboard selected on the command line
+func mapTerminal(m *sim.Machine, screen bool, screenAddr, screenSize string, keyboard bool, keyboardAddr string) (*terminal, error) {
+	t := &terminal{done: make(chan struct{})}
+
+	if screen {
+		addr, err := strconv.ParseInt(screenAddr, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid screen address: %s", screenAddr)
+		}
+
+		cols, rows, err := parseDimensions("screen", screenSize)
+		if err != nil {
+			return nil, err
+		}
+
+		t.screen = sim.NewTextScreen(cols, rows)
+		if err := m.MapRegion(int(addr), t.screen.Size(), t.screen); err != nil {
+			return nil, fmt.Errorf("failed to map screen: %w", err)
+		}
+	}
+
+	if keyboard {
+		addr, err := strconv.ParseInt(keyboardAddr, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyboard address: %s", keyboardAddr)
+		}
+
+		t.keyboard = sim.NewKeyboard()
+		if err := m.MapRegion(int(addr), 1, t.keyboard); err != nil {
+			return nil, fmt.Errorf("failed to map keyboard: %w", err)
+		}
+	}
+
+	return t, nil
+}
+
+// parseDimensions parses the size of a screen in the form WIDTHxHEIGHT
+func parseDimensions(name, str string) (int, int, error) {
+	parts := strings.Split(strings.ToLower(str), "x")
+
+	if len(parts) == 2 {
+		width, werr := strconv.Atoi(parts[0])
+		height, herr := strconv.Atoi(parts[1])
+
+		if werr == nil && herr == nil && width > 0 && height > 0 {
+			return width, height, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("invalid %s size: %s (expected WIDTHxHEIGHT)", name, str)
+}
+
+// start feeds the keyboard from stdin, switching the terminal to unbuffered input without echo,
+// and, if live is set, redraws the screen while the program runs. Ctrl-C still interrupts the program.
+func (t *terminal) start(live bool) {
+	if t.keyboard != nil {
+		cmd := exec.Command("stty", "-g")
+		cmd.Stdin = os.Stdin
+
+		if state, err := cmd.Output(); err == nil {
+			t.stty = strings.TrimSpace(string(state))
+			stty("-icanon", "-echo", "min", "1")
+		}
+
+		go t.keyboard.Feed(os.Stdin)
+	}
+
+	if t.screen != nil && live {
+		t.live = true
+		t.wg.Add(1)
+
+		go func() {
+			defer t.wg.Done()
+
+			ticker := time.NewTicker(refreshInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					if t.screen.Changed() {
+						t.draw()
+					}
+				case <-t.done:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// stop stops redrawing the screen, draws it one last time and restores the terminal settings
+func (t *terminal) stop() {
+	close(t.done)
+	t.wg.Wait()
+
+	if t.live {
+		t.draw()
+	}
+
+	if t.stty != "" {
+		stty(t.stty)
+	}
+}
+
+// draw clears the terminal and prints the screen
+func (t *terminal) draw() {
+	fmt.Print("\x1b[H\x1b[2J" + t.screen.Text())
+}
+
+// dump writes the screen's text to path, or to stdout if path is -
+func (t *terminal) dump(path string) error {
+	if t.screen == nil {
+		return fmt.Errorf("no screen is mapped")
+	}
+
+	if path == "-" {
+		fmt.Print(t.screen.Text())
+		return nil
+	}
+
+	return os.WriteFile(path, []byte(t.screen.Text()), 0644)
+}
+
+// isTerminal reports if f is connected to a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stty changes the settings of the terminal connected to stdin
+func stty(args ...string) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Run()
+}
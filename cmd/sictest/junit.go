@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// junitSuites is the root element of a JUnit XML report
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+// junitSuite is the report of a spec file
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+// junitCase is the report of a case
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitMessage is a failure or error, with a summary and the full text
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// add adds the results of a suite to the report
+func (r *junitSuites) add(name string, results []caseResult) {
+	suite := junitSuite{Name: name, Tests: len(results)}
+	var total time.Duration
+
+	for _, res := range results {
+		tc := junitCase{Name: res.name, Classname: name, Time: seconds(res.elapsed), SystemOut: string(res.stdout)}
+
+		if res.err != nil {
+			tc.Error = &junitMessage{Message: res.err.Error(), Text: res.err.Error()}
+			suite.Errors++
+		} else if len(res.failures) > 0 {
+			summary := strings.SplitN(res.failures[0], "\n", 2)[0]
+			tc.Failure = &junitMessage{Message: summary, Text: strings.Join(res.failures, "\n")}
+			suite.Failures++
+		}
+
+		total += res.elapsed
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suite.Time = seconds(total)
+	r.Suites = append(r.Suites, suite)
+	r.Tests += suite.Tests
+	r.Failures += suite.Failures
+	r.Errors += suite.Errors
+}
+
+// write writes the report to path
+func (r *junitSuites) write(path string, elapsed time.Duration) error {
+	r.Time = seconds(elapsed)
+
+	data, err := xml.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+
+	data = append([]byte(xml.Header), append(data, '\n')...)
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+
+	return nil
+}
+
+// seconds formats a duration as seconds, as JUnit reports expect
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
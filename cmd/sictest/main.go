@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	opt "github.com/pborman/getopt/v2"
+)
+
+func main() {
+	// Flags
+	helpFlag := opt.BoolLong("help", 'h', "Show this text")
+	junitFlag := opt.StringLong("junit", 'j', "", "Write a JUnit XML report to file", "file")
+	runFlag := opt.StringLong("run", 'r', "", "Only run cases whose name contains text", "text")
+	stepsFlag := opt.IntLong("steps", 's', defaultSteps, "Step limit of cases that don't set one", "n")
+	verboseFlag := opt.BoolLong("verbose", 'v', "Print the output of device 1 for every case")
+	opt.SetParameters("spec...")
+	opt.Parse()
+
+	if *helpFlag {
+		help()
+		os.Exit(0)
+	}
+
+	if opt.NArgs() == 0 {
+		fmt.Printf("No spec provided!\n\n")
+		help()
+		os.Exit(2)
+	}
+
+	r, err := newRunner()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	var report junitSuites
+	var passed, failed, errors int
+
+	for _, path := range opt.Args() {
+		suite, err := loadSuite(path, *stepsFlag)
+		if err != nil {
+			fmt.Printf("ERROR %v\n", err)
+			report.add(path, []caseResult{{name: path, err: err}})
+			errors++
+			continue
+		}
+
+		fmt.Printf("=== %s (%s)\n", suite.Name, path)
+		var results []caseResult
+
+		for _, c := range suite.Cases {
+			if *runFlag != "" && !strings.Contains(c.Name, *runFlag) {
+				continue
+			}
+
+			res := r.run(c)
+			results = append(results, res)
+			printResult(res, *verboseFlag)
+
+			switch {
+			case res.err != nil:
+				errors++
+			case len(res.failures) > 0:
+				failed++
+			default:
+				passed++
+			}
+		}
+
+		report.add(suite.Name, results)
+	}
+
+	r.close()
+	fmt.Printf("\n%d passed, %d failed, %d errors (%.3fs)\n", passed, failed, errors, time.Since(start).Seconds())
+
+	if *junitFlag != "" {
+		if err := report.write(*junitFlag, time.Since(start)); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	if failed > 0 || errors > 0 {
+		os.Exit(1)
+	}
+}
+
+// printResult prints whether a case passed, with the differences from the expected results if it didn't
+func printResult(res caseResult, verbose bool) {
+	switch {
+	case res.err != nil:
+		fmt.Printf("ERROR %s: %v\n", res.name, res.err)
+	case len(res.failures) > 0:
+		fmt.Printf("FAIL  %s (%d instructions)\n", res.name, res.res.Instructions)
+
+		for _, failure := range res.failures {
+			fmt.Printf("      %s\n", strings.ReplaceAll(failure, "\n", "\n      "))
+		}
+	default:
+		fmt.Printf("PASS  %s (%d instructions)\n", res.name, res.res.Instructions)
+	}
+
+	if verbose && res.err == nil {
+		fmt.Printf("      output: %q\n", res.stdout)
+	}
+}
+
+func help() {
+	fmt.Println("Usage: sictest (-hv) (-j file) (-r text) (-s n) spec...")
+	fmt.Println()
+	fmt.Println("  -h, --help           Print this text")
+	fmt.Println("  -j, --junit file     Write a JUnit XML report to file")
+	fmt.Println("  -r, --run text       Only run cases whose name contains text")
+	fmt.Println("  -s, --steps n        Step limit of cases that don't set one (default 100000)")
+	fmt.Println("  -v, --verbose        Print the output of device 1 for every case")
+	fmt.Println()
+	fmt.Println("Specs are JSON files:")
+	fmt.Println()
+	fmt.Println("  {")
+	fmt.Println("    \"program\": \"max.asm\",")
+	fmt.Println("    \"steps\": 1000,")
+	fmt.Println("    \"cases\": [{")
+	fmt.Println("      \"name\": \"larger of 3 and 7\",")
+	fmt.Println("      \"memory\": {\"A\": 3, \"B\": 7},")
+	fmt.Println("      \"input\": {\"F1\": \"abc\\n\"},")
+	fmt.Println("      \"expect\": {")
+	fmt.Println("        \"stop\": \"halted\",")
+	fmt.Println("        \"output\": {\"01\": \"7\\n\"},")
+	fmt.Println("        \"registers\": {\"A\": 7},")
+	fmt.Println("        \"memory\": {\"RES\": 7}")
+	fmt.Println("      }")
+	fmt.Println("    }]")
+	fmt.Println("  }")
+	fmt.Println()
+	fmt.Println("  program    .obj or .asm file, relative to the spec, of all cases or a single case")
+	fmt.Println("  steps      Step limit of all cases or a single case")
+	fmt.Println("  registers  Initial or expected registers by name (A, X, L, B, S, T, F, PC, SW)")
+	fmt.Println("  memory     Initial or expected memory by address: a label, :line, #decimal or hex number")
+	fmt.Println("  input      Input of devices by hex number")
+	fmt.Println("  output     Expected output of devices by hex number")
+	fmt.Println("  stop       How the run ends: halted (default), fault or step limit")
+	fmt.Println()
+	fmt.Println("  Numbers are words, strings and [..] lists are bytes.")
+	fmt.Println()
+	fmt.Println("  Each case runs on a new machine. Devices 0-2 and the devices with input or expected")
+	fmt.Println("  output are in-memory buffers, the other devices use XX.dev in a temporary directory")
+	fmt.Println("  of the case. Strings are encoded in UTF-8, so write bytes above 7F as [..] lists.")
+	fmt.Println()
+	fmt.Println("Exit codes:")
+	fmt.Println("  0    All cases passed")
+	fmt.Println("  1    A case failed or couldn't be run")
+	fmt.Println("  2    Invalid arguments")
+	fmt.Println()
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erazemk/sicsim/asm"
+	"github.com/erazemk/sicsim/sim"
+)
+
+// maxDiffLines limits the lines shown in a diff of device output
+const maxDiffLines = 20
+
+// runner runs cases, assembling each .asm program once into a work directory, which also
+// holds a directory per case for the files of devices the case doesn't map
+type runner struct {
+	dir  string
+	objs map[string]string
+}
+
+// caseResult is the outcome of a case
+type caseResult struct {
+	name     string
+	failures []string // Differences from the expected results
+	err      error    // Set if the case couldn't be run
+	res      sim.Result
+	stdout   []byte // Output of device 1
+	elapsed  time.Duration
+}
+
+// newRunner creates the runner's work directory
+func newRunner() (*runner, error) {
+	dir, err := os.MkdirTemp("", "sictest")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+
+	return &runner{dir: dir, objs: make(map[string]string)}, nil
+}
+
+// close removes the work directory
+func (r *runner) close() {
+	os.RemoveAll(r.dir)
+}
+
+// object returns the object file of program, assembling it if it is an .asm file
+func (r *runner) object(program string) (string, error) {
+	if !strings.EqualFold(filepath.Ext(program), ".asm") {
+		return program, nil
+	}
+
+	if obj, ok := r.objs[program]; ok {
+		return obj, nil
+	}
+
+	obj := filepath.Join(r.dir, fmt.Sprintf("prog%d.obj", len(r.objs)))
+	if err := asm.AssembleFile(program, obj); err != nil {
+		return "", err
+	}
+
+	r.objs[program] = obj
+	return obj, nil
+}
+
+// run runs a case on a new machine and compares the results with the expected ones
+func (r *runner) run(c Case) caseResult {
+	start := time.Now()
+	result := caseResult{name: c.Name}
+
+	// Each case gets its own device files, so cases don't see each other's output
+	dir, err := os.MkdirTemp(r.dir, "case")
+	if err != nil {
+		result.err = fmt.Errorf("failed to create device directory: %w", err)
+		result.elapsed = time.Since(start)
+		return result
+	}
+
+	defer os.RemoveAll(dir)
+
+	m, devs, err := r.setup(c, dir)
+	if err != nil {
+		result.err = err
+		result.elapsed = time.Since(start)
+		return result
+	}
+
+	result.res = m.Run(context.Background(), sim.Limits{Steps: c.Steps})
+	m.Close()
+
+	result.stdout = devs[1].Output()
+	result.failures = check(m, c.Expect, result.res, devs)
+	result.elapsed = time.Since(start)
+	return result
+}
+
+// setup creates a machine, loads the case's program and sets its registers, memory and device input.
+// Devices 0-2 and the devices with input or expected output are in-memory buffers, the
+// others use files in dir.
+func (r *runner) setup(c Case, dir string) (*sim.Machine, map[byte]*sim.BufferDevice, error) {
+	obj, err := r.object(c.Program)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var m sim.Machine
+	m.New()
+	m.SetDeviceDir(dir)
+
+	devs := map[byte]*sim.BufferDevice{0: sim.NewBufferDevice(nil), 1: sim.NewBufferDevice(nil), 2: sim.NewBufferDevice(nil)}
+
+	for _, name := range sortedKeys(c.Input) {
+		id, err := parseDevice(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !c.Input[name].IsBytes {
+			return nil, nil, fmt.Errorf("input of device %s must be a string or a list of bytes", name)
+		}
+
+		devs[id] = sim.NewBufferDevice(c.Input[name].Bytes)
+	}
+
+	for _, name := range sortedKeys(c.Expect.Output) {
+		id, err := parseDevice(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !c.Expect.Output[name].IsBytes {
+			return nil, nil, fmt.Errorf("expected output of device %s must be a string or a list of bytes", name)
+		}
+
+		if devs[id] == nil {
+			devs[id] = sim.NewBufferDevice(nil)
+		}
+	}
+
+	for id, dev := range devs {
+		m.AttachDevice(id, dev)
+	}
+
+	if err := m.ParseObjFile(obj); err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range sortedKeys(c.Registers) {
+		no, ok := sim.RegisterNumber(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid register: %s", name)
+		}
+
+		if c.Registers[name].IsBytes {
+			return nil, nil, fmt.Errorf("value of register %s must be a number", name)
+		}
+
+		if err := m.SetReg(no, c.Registers[name].Num); err != nil {
+			return nil, nil, fmt.Errorf("failed to set register %s: %w", name, err)
+		}
+	}
+
+	for _, name := range sortedKeys(c.Memory) {
+		addr, err := m.ParseAddr(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if val := c.Memory[name]; val.IsBytes {
+			err = m.WriteMem(addr, val.Bytes)
+		} else {
+			err = m.SetWord(addr, val.Num)
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to set memory %s: %w", name, err)
+		}
+	}
+
+	return &m, devs, nil
+}
+
+// check compares the machine's state after a run with the expected results, returning the differences
+func check(m *sim.Machine, want Expect, res sim.Result, devs map[byte]*sim.BufferDevice) []string {
+	var failures []string
+
+	stop := want.Stop
+	if stop == "" {
+		stop = "halted"
+	}
+
+	if res.Reason != stopReasons[stop] {
+		got := res.Reason.String()
+		if res.Fault != nil {
+			got += fmt.Sprintf(" (%v)", res.Fault)
+		}
+
+		failures = append(failures, fmt.Sprintf("stop: got %s at %06X, want %s", got, res.PC, stop))
+	}
+
+	for _, name := range sortedKeys(want.Output) {
+		id, _ := parseDevice(name)
+
+		if got := devs[id].Output(); !bytes.Equal(got, want.Output[name].Bytes) {
+			failures = append(failures, fmt.Sprintf("output of device %02X:\n%s", id, diff(want.Output[name].Bytes, got)))
+		}
+	}
+
+	for _, name := range sortedKeys(want.Registers) {
+		no, ok := sim.RegisterNumber(name)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("invalid register: %s", name))
+			continue
+		}
+
+		got, _ := m.Reg(no)
+
+		if w := want.Registers[name]; w.IsBytes || got&0xFFFFFF != w.Num&0xFFFFFF {
+			failures = append(failures, fmt.Sprintf("register %s: got %s, want %s", strings.ToUpper(name), Value{Num: got}, w))
+		}
+	}
+
+	for _, name := range sortedKeys(want.Memory) {
+		addr, err := m.ParseAddr(name)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+
+		w := want.Memory[name]
+		var got Value
+
+		if w.IsBytes {
+			got.Bytes, err = m.ReadMem(addr, len(w.Bytes))
+			got.IsBytes = true
+		} else {
+			got.Num, err = m.Word(addr)
+		}
+
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("memory %s: %v", name, err))
+		} else if w.IsBytes && !bytes.Equal(got.Bytes, w.Bytes) || !w.IsBytes && got.Num&0xFFFFFF != w.Num&0xFFFFFF {
+			failures = append(failures, fmt.Sprintf("memory %s: got %s, want %s", name, got, w))
+		}
+	}
+
+	return failures
+}
+
+// diff describes how the device output got differs from want. Single lines are shown side
+// by side, longer output line by line, with - marking expected and + actual lines.
+func diff(want, got []byte) string {
+	wantLines, gotLines := splitLines(want), splitLines(got)
+
+	if len(wantLines) <= 1 && len(gotLines) <= 1 {
+		return fmt.Sprintf("  got  %q\n  want %q", got, want)
+	}
+
+	var sb strings.Builder
+	shown := 0
+
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		if shown == maxDiffLines {
+			sb.WriteString("  ...\n")
+			break
+		}
+
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+
+		if w == g {
+			fmt.Fprintf(&sb, "    %q\n", w)
+		} else {
+			if i < len(wantLines) {
+				fmt.Fprintf(&sb, "  - %q\n", w)
+			}
+
+			if i < len(gotLines) {
+				fmt.Fprintf(&sb, "  + %q\n", g)
+			}
+		}
+
+		shown++
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// splitLines splits output into lines, keeping their newlines
+func splitLines(output []byte) []string {
+	lines := strings.SplitAfter(string(output), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// parseDevice parses a device number in hex
+func parseDevice(name string) (byte, error) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(name), "0x"), 16, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid device: %s", name)
+	}
+
+	return byte(id), nil
+}
+
+// sortedKeys returns the keys of a map of values in order, so results are reported deterministically
+func sortedKeys(values map[string]Value) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// defaultSteps is the step limit of cases that don't set one
+const defaultSteps = 100000
+
+// Suite is a spec file: a program and the cases it is tested with
+type Suite struct {
+	Name    string `json:"name"`    // Name of the suite, the spec's file name by default
+	Program string `json:"program"` // Object (.obj) or assembly (.asm) file, relative to the spec
+	Steps   int    `json:"steps"`   // Default step limit of the cases
+	Cases   []Case `json:"cases"`
+}
+
+// Case is a single run of a program
+type Case struct {
+	Name      string           `json:"name"`
+	Program   string           `json:"program"`   // Overrides the suite's program
+	Steps     int              `json:"steps"`     // Maximum number of instructions to execute
+	Registers map[string]Value `json:"registers"` // Initial register values, by name
+	Memory    map[string]Value `json:"memory"`    // Initial memory, by address as parsed by sim.Machine.ParseAddr
+	Input     map[string]Value `json:"input"`     // Input of devices, by hex device number
+	Expect    Expect           `json:"expect"`
+}
+
+// Expect holds the expected results of a case
+type Expect struct {
+	Stop      string           `json:"stop"`      // How the run ends: halted (default), fault or step limit
+	Output    map[string]Value `json:"output"`    // Output of devices, by hex device number
+	Registers map[string]Value `json:"registers"` // Final register values, by name
+	Memory    map[string]Value `json:"memory"`    // Final memory, by address as parsed by sim.Machine.ParseAddr
+}
+
+// stopReasons are the values of Expect.Stop and the stop reasons they stand for
+var stopReasons = map[string]sim.StopReason{
+	"halted":     sim.StopHalt,
+	"fault":      sim.StopFault,
+	"step limit": sim.StopStepLimit,
+}
+
+// Value is a number, which stands for a word in memory, or bytes given as a string or a list of numbers
+type Value struct {
+	Num     int
+	Bytes   []byte
+	IsBytes bool
+}
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var val interface{}
+	if err := json.Unmarshal(data, &val); err != nil {
+		return err
+	}
+
+	switch val := val.(type) {
+	case float64:
+		if val != float64(int(val)) {
+			return fmt.Errorf("not an integer: %v", val)
+		}
+
+		v.Num = int(val)
+	case string:
+		v.Bytes, v.IsBytes = []byte(val), true
+	case []interface{}:
+		v.Bytes, v.IsBytes = make([]byte, 0, len(val)), true
+
+		for _, item := range val {
+			num, ok := item.(float64)
+			if !ok || num < -128 || num > 255 || num != float64(int(num)) {
+				return fmt.Errorf("not a byte: %v", item)
+			}
+
+			v.Bytes = append(v.Bytes, byte(num))
+		}
+	default:
+		return fmt.Errorf("expected a number, a string or a list of bytes, got %v", val)
+	}
+
+	return nil
+}
+
+// String formats the value as it is shown in diffs
+func (v Value) String() string {
+	if v.IsBytes {
+		return fmt.Sprintf("%q", v.Bytes)
+	}
+
+	return fmt.Sprintf("%06X (%d)", v.Num&0xFFFFFF, v.Num)
+}
+
+// loadSuite reads a JSON spec file. Cases without a step limit use the suite's, or else steps.
+func loadSuite(path string, steps int) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %w", err)
+	}
+
+	suite := &Suite{}
+	if err := json.Unmarshal(data, suite); err != nil {
+		return nil, fmt.Errorf("failed to parse spec %s: %w", path, err)
+	}
+
+	if suite.Name == "" {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("spec %s has no cases", path)
+	}
+
+	for i := range suite.Cases {
+		c := &suite.Cases[i]
+
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", i+1)
+		}
+
+		if c.Program == "" {
+			c.Program = suite.Program
+		}
+
+		if c.Program == "" {
+			return nil, fmt.Errorf("spec %s: %s has no program", path, c.Name)
+		}
+
+		if !filepath.IsAbs(c.Program) {
+			c.Program = filepath.Join(filepath.Dir(path), c.Program)
+		}
+
+		if c.Steps == 0 {
+			c.Steps = suite.Steps
+		}
+
+		if c.Steps == 0 {
+			c.Steps = steps
+		}
+
+		if _, ok := stopReasons[c.Expect.Stop]; !ok && c.Expect.Stop != "" {
+			return nil, fmt.Errorf("spec %s: %s expects an unknown stop %q", path, c.Name, c.Expect.Stop)
+		}
+	}
+
+	return suite, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erazemk/sicsim/sim"
+)
+
+// writeSpec writes a spec to a temporary directory and returns its path
+func writeSpec(t *testing.T, spec string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "spec.json")
+
+	if err := os.WriteFile(path, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// TestLoadSuite checks the defaults of cases and rejected specs
+func TestLoadSuite(t *testing.T) {
+	path := writeSpec(t, `{
+		"program": "prog.obj",
+		"steps": 50,
+		"cases": [
+			{"memory": {"RES": 7, "BUF": "ab", "RAW": [255, -1]}},
+			{"name": "own", "program": "/other.asm", "steps": 5, "expect": {"stop": "step limit"}}
+		]
+	}`)
+
+	suite, err := loadSuite(path, defaultSteps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := suite.Cases[0], suite.Cases[1]
+
+	if suite.Name != "spec" || first.Name != "case 1" || second.Name != "own" {
+		t.Errorf("names %q, %q and %q, want spec, case 1 and own", suite.Name, first.Name, second.Name)
+	}
+
+	if first.Program != filepath.Join(filepath.Dir(path), "prog.obj") || second.Program != "/other.asm" {
+		t.Errorf("programs %s and %s", first.Program, second.Program)
+	}
+
+	if first.Steps != 50 || second.Steps != 5 {
+		t.Errorf("step limits %d and %d, want 50 and 5", first.Steps, second.Steps)
+	}
+
+	if mem := first.Memory; mem["RES"].Num != 7 || string(mem["BUF"].Bytes) != "ab" || string(mem["RAW"].Bytes) != "\xff\xff" {
+		t.Errorf("memory %v", mem)
+	}
+
+	invalid := []string{
+		`{"program": "p.obj", "cases": []}`,
+		`{"cases": [{}]}`,
+		`{"program": "p.obj", "cases": [{"expect": {"stop": "halt"}}]}`,
+		`{"program": "p.obj", "cases": [{"memory": {"A": 1.5}}]}`,
+		`{"program": "p.obj", "cases": [{"memory": {"A": [256]}}]}`,
+		"program: p.obj\ncases:\n  - name: yaml\n",
+	}
+
+	for _, spec := range invalid {
+		if _, err := loadSuite(writeSpec(t, spec), defaultSteps); err == nil {
+			t.Errorf("loaded %s", spec)
+		}
+	}
+}
+
+// TestCheckStop checks that the expected stop must match the stop reason exactly
+func TestCheckStop(t *testing.T) {
+	var m sim.Machine
+	m.New()
+
+	tests := []struct {
+		stop   string
+		reason sim.StopReason
+		ok     bool
+	}{
+		{"", sim.StopHalt, true},
+		{"halted", sim.StopHalt, true},
+		{"fault", sim.StopFault, true},
+		{"step limit", sim.StopStepLimit, true},
+		{"halted", sim.StopStepLimit, false},
+		{"step limit", sim.StopHalt, false},
+		{"", sim.StopFault, false},
+	}
+
+	for _, tt := range tests {
+		failures := check(&m, Expect{Stop: tt.stop}, sim.Result{Reason: tt.reason}, nil)
+
+		if ok := len(failures) == 0; ok != tt.ok {
+			t.Errorf("stop %q with %s: got failures %q", tt.stop, tt.reason, failures)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package debuginfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Extension is the file extension of debug information files
+const Extension = ".dbg"
+
+// Symbol kinds
+const (
+	KindLabel = "label" // Address of a line
+	KindEqu   = "equ"   // Value set with EQU
+)
+
+// Info is the debug information of an assembled program
+type Info struct {
+	Program  string    `json:"program"` // Name from START
+	Source   string    `json:"source"`  // Path of the assembly source, relative to the debug info file
+	Start    int       `json:"start"`   // Start address
+	Entry    int       `json:"entry"`   // Address of the first instruction
+	Length   int       `json:"length"`  // Length of the program in bytes
+	Symbols  []Symbol  `json:"symbols"`
+	Lines    []Line    `json:"lines"`
+	Sections []Section `json:"sections"`
+	Blocks   []Block   `json:"blocks"`
+	Data     []Data    `json:"data"`
+}
+
+// Symbol is an entry of the symbol table
+type Symbol struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+	Kind  string `json:"kind"`
+}
+
+// Line maps an instruction or data item to its source line
+type Line struct {
+	Addr   int  `json:"addr"`
+	Length int  `json:"length"`
+	Line   int  `json:"line"`
+	Code   bool `json:"code"` // The line holds an instruction
+}
+
+// Section is a control section, started by START
+type Section struct {
+	Name   string `json:"name"`
+	Start  int    `json:"start"`
+	Length int    `json:"length"`
+}
+
+// Block is a contiguous range of assembled bytes. ORG starts a new block.
+type Block struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// Data is a labelled data item reserved with BYTE, WORD, RESB or RESW
+type Data struct {
+	Name string `json:"name"`
+	Addr int    `json:"addr"`
+	Size int    `json:"size"` // Size in bytes
+	Type string `json:"type"` // Directive that reserved the item
+}
+
+// Path returns the path of the debug information file of an object file
+func Path(obj string) string {
+	return strings.TrimSuffix(obj, filepath.Ext(obj)) + Extension
+}
+
+// Load reads a debug information file. The source path is resolved relative to its directory.
+func Load(path string) (*Info, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read debug info: %w", err)
+	}
+
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse debug info: %w", err)
+	}
+
+	if info.Source != "" {
+		info.Source = filepath.FromSlash(info.Source)
+		if !filepath.IsAbs(info.Source) {
+			info.Source = filepath.Join(filepath.Dir(path), info.Source)
+		}
+	}
+
+	return &info, nil
+}
+
+// Write writes the debug information to path, storing the source path relative to it
+func (info *Info) Write(path string) error {
+	out := *info
+
+	dir, derr := filepath.Abs(filepath.Dir(path))
+	src, serr := filepath.Abs(info.Source)
+
+	if derr == nil && serr == nil {
+		if rel, err := filepath.Rel(dir, src); err == nil {
+			out.Source = rel
+		}
+	}
+
+	out.Source = filepath.ToSlash(out.Source)
+
+	data, err := json.MarshalIndent(&out, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode debug info: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write debug info: %w", err)
+	}
+
+	return nil
+}
+
+// SymbolTable returns the symbols as a map from name to value
+func (info *Info) SymbolTable() map[string]int {
+	symbols := make(map[string]int, len(info.Symbols))
+
+	for _, sym := range info.Symbols {
+		symbols[sym.Name] = sym.Value
+	}
+
+	return symbols
+}
+
+// LineOf returns the source line of the instruction or data item containing addr
+func (info *Info) LineOf(addr int) (int, bool) {
+	for _, l := range info.Lines {
+		if addr >= l.Addr && addr < l.Addr+l.Length {
+			return l.Line, true
+		}
+	}
+
+	return 0, false
+}
+
+// AddrOf returns the address of the first instruction or data item on a source line
+func (info *Info) AddrOf(line int) (int, bool) {
+	for _, l := range info.Lines {
+		if l.Line == line {
+			return l.Addr, true
+		}
+	}
+
+	return 0, false
+}
+
+// DataAt returns the data item starting at addr
+func (info *Info) DataAt(addr int) (Data, bool) {
+	for _, d := range info.Data {
+		if d.Addr == addr {
+			return d, true
+		}
+	}
+
+	return Data{}, false
+}
+
+// Sort orders symbols by value and name, and lines, blocks and data by address
+func (info *Info) Sort() {
+	sort.Slice(info.Symbols, func(i, j int) bool {
+		if info.Symbols[i].Value != info.Symbols[j].Value {
+			return info.Symbols[i].Value < info.Symbols[j].Value
+		}
+
+		return info.Symbols[i].Name < info.Symbols[j].Name
+	})
+
+	sort.SliceStable(info.Lines, func(i, j int) bool { return info.Lines[i].Addr < info.Lines[j].Addr })
+	sort.SliceStable(info.Blocks, func(i, j int) bool { return info.Blocks[i].Start < info.Blocks[j].Start })
+	sort.SliceStable(info.Data, func(i, j int) bool { return info.Data[i].Addr < info.Data[j].Addr })
+}
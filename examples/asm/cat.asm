@@ -0,0 +1,14 @@
+. program
+cat		START	0
+
+. cakaj na vhod
+loop	TD		#0
+		JEQ		loop
+		JGT		halt
+
+. prepisi znak
+		RD		#0
+		WD		#1
+		J		loop
+
+halt	J		halt
@@ -0,0 +1,45 @@
+package sim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseAddr parses an address given as a label, a source line (:line), a decimal number
+// prefixed with # or a hex number, optionally prefixed with 0x. Labels take precedence over hex.
+func (m *Machine) ParseAddr(str string) (int, error) {
+	if addr, ok := m.symbolAddr(str); ok {
+		return addr, nil
+	}
+
+	var addr int64
+	var err error
+
+	if strings.HasPrefix(str, "#") {
+		addr, err = strconv.ParseInt(str[1:], 10, 32)
+	} else {
+		addr, err = strconv.ParseInt(strings.TrimPrefix(strings.ToLower(str), "0x"), 16, 32)
+	}
+
+	if err != nil || addr < 0 {
+		return 0, fmt.Errorf("invalid address: %s", str)
+	}
+
+	return int(addr), nil
+}
+
+// symbolAddr resolves a label or a source line in the form :line, using the loaded debug info
+func (m *Machine) symbolAddr(str string) (int, bool) {
+	if strings.HasPrefix(str, ":") {
+		line, err := strconv.Atoi(str[1:])
+		if err != nil {
+			return 0, false
+		}
+
+		return m.LineAddr(line)
+	}
+
+	addr, ok := m.symbols[str]
+	return addr, ok
+}
@@ -0,0 +1,44 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/erazemk/sicsim/debuginfo"
+)
+
+// TestParseAddr checks the address syntax and that labels shadow hex numbers
+func TestParseAddr(t *testing.T) {
+	var m Machine
+	m.New()
+	m.SetDebugInfo(&debuginfo.Info{
+		Symbols: []debuginfo.Symbol{{Name: "ADD", Value: 0x40}, {Name: "LOOP", Value: 0x33}},
+		Lines:   []debuginfo.Line{{Addr: 0x30, Length: 3, Line: 4, Code: true}},
+	})
+
+	tests := []struct {
+		str  string
+		want int
+	}{
+		{"10", 0x10},
+		{"0x10", 0x10},
+		{"0XFF", 0xFF},
+		{"#10", 10},
+		{"ADD", 0x40},
+		{"0xADD", 0xADD},
+		{"add", 0xADD},
+		{"LOOP", 0x33},
+		{":4", 0x30},
+	}
+
+	for _, tt := range tests {
+		if got, err := m.ParseAddr(tt.str); err != nil || got != tt.want {
+			t.Errorf("%q: got %06X, %v, want %06X", tt.str, got, err, tt.want)
+		}
+	}
+
+	for _, str := range []string{"", "LOOPS", "#-1", "-1", "#0x10", ":5", ":x", "0x"} {
+		if addr, err := m.ParseAddr(str); err == nil {
+			t.Errorf("%q: got %06X, want an error", str, addr)
+		}
+	}
+}
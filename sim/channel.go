@@ -0,0 +1,261 @@
+package sim
+
+import (
+	"fmt"
+)
+
+// Channels is the number of I/O channels, selected by register A in SIO, TIO and HIO
+const Channels = 16
+
+// Channel commands. A channel program is a list of command words, each 3 words (9 bytes) long:
+//
+//	0  command, device number and an unused byte
+//	3  memory address of the block
+//	6  number of bytes in the block
+//
+// The channel executes commands in order until ChannelHalt.
+const (
+	ChannelHalt  = 0x00 // End of the channel program
+	ChannelRead  = 0x01 // Read a block from the device into memory
+	ChannelWrite = 0x02 // Write a block from memory to the device
+)
+
+// commandSize is the size of a channel command word
+const commandSize = 9
+
+// ChannelStatus is the status of an I/O channel reported by TIO
+type ChannelStatus int
+
+const (
+	ChannelIdle  ChannelStatus = iota // TIO sets CC to LT, the last channel program completed
+	ChannelBusy                       // TIO sets CC to EQ, a channel program is running
+	ChannelError                      // TIO sets CC to GT, the last channel program failed
+)
+
+func (s ChannelStatus) String() string {
+	switch s {
+	case ChannelIdle:
+		return "idle"
+	case ChannelBusy:
+		return "busy"
+	case ChannelError:
+		return "error"
+	}
+
+	return fmt.Sprintf("unknown channel status (%d)", int(s))
+}
+
+// cc returns the condition code TIO sets for the status
+func (s ChannelStatus) cc() int {
+	switch s {
+	case ChannelIdle:
+		return LT
+	case ChannelBusy:
+		return EQ
+	}
+
+	return GT
+}
+
+// channel is an I/O channel running a channel program
+type channel struct {
+	active bool
+	prog   int   // Address of the next command word
+	err    error // Error that stopped the last channel program
+
+	loaded bool // A read or write command is in progress
+	cmd    byte
+	dev    byte
+	addr   int // Address of the next byte of the block
+	count  int // Number of bytes left in the block
+
+	credit int // Cycles available for transferring bytes
+}
+
+// StartIO starts the channel program at prog on channel ch (SIO).
+// It returns an error if the channel is busy.
+func (m *Machine) StartIO(ch, prog int) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+
+	if m.channels[ch].active {
+		return fmt.Errorf("channel %d is busy", ch)
+	}
+
+	m.channels[ch] = channel{active: true, prog: prog}
+	m.ioPending &^= 1 << ch
+
+	if m.debug {
+		m.logger.Printf("Started channel %d with program at 0x%06X\n", ch, prog)
+	}
+
+	return nil
+}
+
+// TestIO returns the status of channel ch (TIO)
+func (m *Machine) TestIO(ch int) (ChannelStatus, error) {
+	if err := checkChannel(ch); err != nil {
+		return ChannelIdle, err
+	}
+
+	switch c := &m.channels[ch]; {
+	case c.active:
+		return ChannelBusy, nil
+	case c.err != nil:
+		return ChannelError, nil
+	}
+
+	return ChannelIdle, nil
+}
+
+// ChannelErr returns the error that stopped the last channel program of channel ch, if any
+func (m *Machine) ChannelErr(ch int) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+
+	return m.channels[ch].err
+}
+
+// HaltIO stops the channel program running on channel ch (HIO), without an I/O interrupt
+func (m *Machine) HaltIO(ch int) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+
+	m.channels[ch] = channel{}
+	m.ioPending &^= 1 << ch
+
+	if m.debug {
+		m.logger.Printf("Halted channel %d\n", ch)
+	}
+
+	return nil
+}
+
+// checkChannel returns an error if ch is not a valid channel number
+func checkChannel(ch int) error {
+	if ch < 0 || ch >= Channels {
+		return fmt.Errorf("not a valid channel: %d", ch)
+	}
+
+	return nil
+}
+
+// runChannels advances the running channel programs by the cycles used by an instruction, moving
+// one byte every Timing.ChannelByte cycles (as many as the devices are ready for if it's 0), and
+// takes an I/O interrupt for a finished program.
+// If I/O interrupts are disabled, the interrupt stays pending until they are enabled.
+func (m *Machine) runChannels(cycles int) {
+	for ch := range m.channels {
+		c := &m.channels[ch]
+		if !c.active {
+			continue
+		}
+
+		c.credit += cycles
+
+		for c.active && (m.timing.ChannelByte <= 0 || c.credit >= m.timing.ChannelByte) {
+			if !m.stepChannel(ch) {
+				// Waiting for the device doesn't save up cycles for later transfers
+				c.credit = 0
+				break
+			}
+
+			c.credit -= m.timing.ChannelByte
+		}
+	}
+
+	if m.ioPending == 0 {
+		return
+	}
+
+	for ch := 0; ch < Channels; ch++ {
+		if m.ioPending&(1<<ch) != 0 {
+			if m.interrupt(InterruptIO, ch) {
+				m.ioPending &^= 1 << ch
+			}
+
+			return
+		}
+	}
+}
+
+// stepChannel loads the next command word or transfers a byte on channel ch.
+// It returns false if the channel waits for a busy device.
+func (m *Machine) stepChannel(ch int) bool {
+	c := &m.channels[ch]
+
+	if !c.loaded {
+		word, werr := m.Word(c.prog)
+		addr, aerr := m.Word(c.prog + 3)
+		count, cerr := m.Word(c.prog + 6)
+
+		for _, err := range []error{werr, aerr, cerr} {
+			if err != nil {
+				m.finishChannel(ch, fmt.Errorf("failed to load command at 0x%06X: %w", c.prog, err))
+				return true
+			}
+		}
+
+		c.cmd, c.dev = byte(word>>16), byte(word>>8)
+		c.addr, c.count = addr&0xFFFFFF, count&0xFFFFFF
+		c.prog += commandSize
+
+		switch c.cmd {
+		case ChannelHalt:
+			m.finishChannel(ch, nil)
+		case ChannelRead, ChannelWrite:
+			c.loaded = c.count > 0
+		default:
+			m.finishChannel(ch, fmt.Errorf("unknown channel command 0x%02X at 0x%06X", c.cmd, c.prog-commandSize))
+		}
+
+		return true
+	}
+
+	// The device is only accessed once TD would report it ready, or at the end of input, which
+	// fails the read, so the transfer never waits for a device, even if blocks move at once
+	if m.DeviceState(c.dev) == DeviceBusy {
+		return false
+	}
+
+	var err error
+
+	if c.cmd == ChannelRead {
+		var val byte
+		if val, err = m.ReadDevice(c.dev); err == nil {
+			err = m.SetByte(c.addr, val)
+		}
+	} else {
+		var val byte
+		if val, err = m.Byte(c.addr); err == nil {
+			err = m.WriteDevice(c.dev, val)
+		}
+	}
+
+	if err != nil {
+		m.finishChannel(ch, err)
+		return true
+	}
+
+	c.addr++
+	c.count--
+	c.loaded = c.count > 0
+	return true
+}
+
+// finishChannel stops the channel program of channel ch and requests an I/O interrupt
+func (m *Machine) finishChannel(ch int, err error) {
+	m.channels[ch] = channel{err: err}
+	m.ioPending |= 1 << ch
+
+	if m.debug {
+		if err != nil {
+			m.logger.Printf("Channel %d failed: %v\n", ch, err)
+		} else {
+			m.logger.Printf("Channel %d finished\n", ch)
+		}
+	}
+}
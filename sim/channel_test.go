@@ -0,0 +1,211 @@
+package sim
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// newChannelMachine returns a supervisor mode machine with interrupts disabled and the channel
+// program cmds at 0x300, each command being its command word, address and count
+func newChannelMachine(t *testing.T, cmds ...[3]int) *Machine {
+	t.Helper()
+
+	m := new(Machine)
+	m.New()
+	m.SetDeviceDir(t.TempDir())
+	m.SetSW(SWMode)
+
+	for i, cmd := range cmds {
+		for j, val := range cmd {
+			if err := m.SetWord(0x300+i*commandSize+j*3, val); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return m
+}
+
+// runChannel executes instructions until channel ch isn't busy anymore
+func runChannel(t *testing.T, m *Machine, ch int) ChannelStatus {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if status, _ := m.TestIO(ch); status != ChannelBusy {
+			return status
+		}
+
+		if err := m.Execute(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Fatalf("channel %d didn't finish", ch)
+	return ChannelBusy
+}
+
+// TestChannelProgram runs a channel program copying a block between devices, with the channel
+// moving a byte every 2 cycles and whole blocks at once
+func TestChannelProgram(t *testing.T) {
+	for _, perByte := range []int{2, 0} {
+		m := newChannelMachine(t,
+			[3]int{ChannelRead<<16 | 0x05<<8, 0x400, 3},
+			[3]int{ChannelWrite<<16 | 0x06<<8, 0x400, 3},
+			[3]int{ChannelHalt << 16, 0, 0},
+		)
+
+		timing := m.Timing()
+		timing.ChannelByte = perByte
+		m.SetTiming(timing)
+
+		in, out := NewBufferDevice([]byte("abc")), NewBufferDevice(nil)
+		m.AttachDevice(0x05, in)
+		m.AttachDevice(0x06, out)
+
+		// SIO on channel 1, with the program address in S
+		m.SetA(1)
+		m.SetS(0x300)
+		m.SetByte(0, SIO)
+
+		if err := m.Execute(); err != nil || m.CC() != LT {
+			t.Fatalf("SIO: CC %02X, %v", m.CC(), err)
+		}
+
+		if status := runChannel(t, m, 1); status != ChannelIdle {
+			t.Fatalf("%d cycles per byte: channel is %s, %v", perByte, status, m.ChannelErr(1))
+		}
+
+		if got := string(out.Output()); got != "abc" {
+			t.Errorf("%d cycles per byte: wrote %q, want \"abc\"", perByte, got)
+		}
+
+		if m.ioPending != 1<<1 {
+			t.Errorf("%d cycles per byte: pending I/O interrupts %b, want channel 1", perByte, m.ioPending)
+		}
+
+		// Enabling I/O interrupts takes the pending interrupt after the next instruction
+		area := InterruptIO.WorkArea()
+		m.SetWord(area, SWMode)
+		m.SetWord(area+3, 0x500)
+		m.SetSW(SWMode | InterruptIO.mask())
+
+		if err := m.Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		if m.PC() != 0x500 || m.SW()&SWICode != 1 || m.ioPending != 0 {
+			t.Errorf("%d cycles per byte: PC %06X and SW %06X after the I/O interrupt", perByte, m.PC(), m.SW())
+		}
+	}
+}
+
+// TestChannelErrors checks failed channel programs, busy channels and HIO
+func TestChannelErrors(t *testing.T) {
+	m := newChannelMachine(t, [3]int{0x07 << 16, 0, 0})
+
+	if err := m.StartIO(0, 0x300); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := runChannel(t, m, 0); status != ChannelError || m.ChannelErr(0) == nil {
+		t.Errorf("unknown command: channel is %s", status)
+	}
+
+	m = newChannelMachine(t, [3]int{ChannelRead<<16 | 0x05<<8, 0x400, 2})
+	m.AttachDevice(0x05, NewBufferDevice([]byte("a")))
+
+	if err := m.StartIO(0, 0x300); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := runChannel(t, m, 0); status != ChannelError || !errors.Is(m.ChannelErr(0), io.EOF) {
+		t.Errorf("reading past the end of input: channel is %s, %v", status, m.ChannelErr(0))
+	}
+
+	// A device that is never ready keeps the channel busy until HIO
+	m = newChannelMachine(t, [3]int{ChannelRead<<16 | 0x05<<8, 0x400, 1})
+	m.AttachDevice(0x05, NewMissingDevice())
+	m.StartIO(0, 0x300)
+
+	for i := 0; i < 10; i++ {
+		m.Execute()
+	}
+
+	if err := m.StartIO(0, 0x300); err == nil {
+		t.Error("starting a busy channel succeeded")
+	}
+
+	m.SetA(0)
+	m.SetByte(m.PC(), HIO)
+
+	if err := m.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _ := m.TestIO(0); status != ChannelIdle || m.ioPending != 0 {
+		t.Errorf("HIO: channel is %s with pending interrupts %b", status, m.ioPending)
+	}
+
+	// Channel instructions are privileged
+	m.SetSW(0)
+	m.SetByte(m.PC(), TIO)
+
+	var fault *Fault
+	if err := m.Execute(); !errors.As(err, &fault) || fault.Kind != FaultPrivileged {
+		t.Errorf("TIO in user mode: got %v, want a privileged instruction fault", err)
+	}
+}
+
+// TestChannelWaitsForInput checks that a channel reading a stream without input doesn't stall the
+// CPU, even when it moves blocks at once
+func TestChannelWaitsForInput(t *testing.T) {
+	m := newChannelMachine(t, [3]int{ChannelRead<<16 | 0x05<<8, 0x400, 1})
+
+	timing := m.Timing()
+	timing.ChannelByte = 0
+	m.SetTiming(timing)
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	m.AttachDevice(0x05, NewReaderDevice(pr))
+	defer m.Close()
+
+	if err := m.StartIO(0, 0x300); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			m.Execute()
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the channel stalled the CPU waiting for input")
+	}
+
+	if status, _ := m.TestIO(0); status != ChannelBusy {
+		t.Fatalf("channel is %s without input, want %s", status, ChannelBusy)
+	}
+
+	go pw.Write([]byte("z"))
+
+	waitFor(t, "the channel to read the input", func() bool {
+		m.Execute()
+		status, _ := m.TestIO(0)
+		return status != ChannelBusy
+	})
+
+	if val, _ := m.Byte(0x400); val != 'z' {
+		t.Errorf("channel read %q, want 'z'", val)
+	}
+}
@@ -0,0 +1,183 @@
+package sim
+
+import (
+	"fmt"
+)
+
+// Clock device modes, selected by writing the mode with WD
+const (
+	ClockCycles       = 0x00 // Machine cycles (default)
+	ClockInstructions = 0x01 // Executed instructions
+	ClockMicroseconds = 0x02 // Simulated time in microseconds, see Machine.Microseconds
+)
+
+// NominalSpeed is the clock frequency in Hz used for simulated time when the machine is unthrottled
+const NominalSpeed = 1000000
+
+// clockSize is the number of bytes of a clock value
+const clockSize = 6
+
+// clockDevice is a read-only device that reports the time elapsed on the machine. RD returns
+// the value as 6 bytes, starting with the most significant one. The value is latched when its
+// first byte is read, so all bytes belong to the same reading.
+type clockDevice struct {
+	m     *Machine
+	mode  byte
+	value [clockSize]byte
+	pos   int // Number of bytes of value already read
+}
+
+// NewClockDevice returns a clock device for the machine, see ClockCycles for the available modes
+func (m *Machine) NewClockDevice() Device {
+	return &clockDevice{m: m}
+}
+
+func (d *clockDevice) Test() bool {
+	return true
+}
+
+func (d *clockDevice) Read() (byte, error) {
+	if d.pos == 0 {
+		var val int64
+
+		switch d.mode {
+		case ClockCycles:
+			val = int64(d.m.cycles)
+		case ClockInstructions:
+			val = int64(d.m.instructions)
+		case ClockMicroseconds:
+			val = d.m.Microseconds()
+		}
+
+		for i := range d.value {
+			d.value[i] = byte(val >> (8 * (clockSize - 1 - i)))
+		}
+	}
+
+	val := d.value[d.pos]
+	d.pos = (d.pos + 1) % clockSize
+	return val, nil
+}
+
+// Write selects the mode and restarts reading at the first byte of the value
+func (d *clockDevice) Write(mode byte) error {
+	switch mode {
+	case ClockCycles, ClockInstructions, ClockMicroseconds:
+		d.mode, d.pos = mode, 0
+		return nil
+	}
+
+	return fmt.Errorf("unknown clock mode: 0x%02X", mode)
+}
+
+// Reset selects cycles and restarts reading at the first byte of the value
+func (d *clockDevice) Reset() error {
+	d.mode, d.pos = ClockCycles, 0
+	return nil
+}
+
+// Microseconds returns the simulated time in microseconds: the used machine cycles at the
+// machine's speed, or at NominalSpeed if it is unthrottled. Unlike wall-clock time it doesn't
+// depend on the host, so runs of the same program report the same times.
+func (m *Machine) Microseconds() int64 {
+	hz := int64(m.speed)
+	if hz == 0 {
+		hz = NominalSpeed
+	}
+
+	return int64(m.cycles) * 1000000 / hz
+}
+
+// Timer returns the number of cycles left until the interval timer expires, 0 if it isn't running
+func (m *Machine) Timer() int {
+	return m.timer
+}
+
+// SetTimer starts the interval timer, which expires after the given number of cycles. If an
+// instruction (STI or WD to the timer device) sets it, it counts from the next instruction.
+// Setting it to 0 stops the timer.
+func (m *Machine) SetTimer(cycles int) error {
+	if cycles < 0 {
+		return fmt.Errorf("invalid timer interval: %d", cycles)
+	}
+
+	m.timer = cycles
+	m.timerSet = true
+	return nil
+}
+
+// TimerExpired reports if the interval timer expired while timer interrupts were disabled,
+// and the expiry hasn't been acknowledged or handled yet
+func (m *Machine) TimerExpired() bool {
+	return m.timerPending
+}
+
+// AckTimer acknowledges the expiry of the interval timer
+func (m *Machine) AckTimer() {
+	m.timerPending = false
+}
+
+// tick counts down the interval timer by the cycles used by an instruction, unless the instruction
+// started the timer. When the timer expires, a timer interrupt is taken. If timer interrupts are
+// disabled, the expiry stays pending until they are enabled or the program acknowledges it through
+// the timer device.
+func (m *Machine) tick(cycles int) {
+	if m.timer > 0 && !m.timerSet {
+		if m.timer -= cycles; m.timer <= 0 {
+			m.timer = 0
+			m.timerPending = true
+
+			if m.debug {
+				m.logger.Println("Interval timer expired")
+			}
+		}
+	}
+
+	if m.timerPending && m.interrupt(InterruptTimer, 0) {
+		m.timerPending = false
+	}
+}
+
+// timerDevice controls the machine's interval timer. WD sets the interval in cycles with 3 bytes,
+// starting with the most significant one. TD is ready once the timer expired while timer
+// interrupts were disabled, and RD acknowledges the expiry, returning 1 if it expired and 0 if not.
+type timerDevice struct {
+	m    *Machine
+	args []byte
+}
+
+// NewTimerDevice returns a device controlling the machine's interval timer
+func (m *Machine) NewTimerDevice() Device {
+	return &timerDevice{m: m}
+}
+
+func (d *timerDevice) Test() bool {
+	return d.m.timerPending
+}
+
+func (d *timerDevice) Read() (byte, error) {
+	if !d.m.timerPending {
+		return 0, nil
+	}
+
+	d.m.AckTimer()
+	return 1, nil
+}
+
+func (d *timerDevice) Write(val byte) error {
+	d.args = append(d.args, val)
+
+	if len(d.args) == 3 {
+		cycles := int(d.args[0])<<16 | int(d.args[1])<<8 | int(d.args[2])
+		d.args = nil
+		return d.m.SetTimer(cycles)
+	}
+
+	return nil
+}
+
+// Reset discards a partially written interval
+func (d *timerDevice) Reset() error {
+	d.args = nil
+	return nil
+}
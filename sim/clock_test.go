@@ -0,0 +1,56 @@
+package sim
+
+import "testing"
+
+// TestMicroseconds checks that simulated time follows the machine's cycles and speed
+func TestMicroseconds(t *testing.T) {
+	var m Machine
+	m.New()
+	m.cycles = 3000
+
+	if us := m.Microseconds(); us != 3000 {
+		t.Errorf("unthrottled: got %d µs, want 3000", us)
+	}
+
+	m.SetSpeed(2000)
+
+	if us := m.Microseconds(); us != 1500000 {
+		t.Errorf("at 2 kHz: got %d µs, want 1500000", us)
+	}
+}
+
+// TestSTITimer checks that the timer set by STI only counts the cycles of later instructions
+func TestSTITimer(t *testing.T) {
+	code := []byte{
+		STI | 0x03, 0x00, 0x09, // 000000 STI 9
+		LDA | 0x01, 0x00, 0x00, // 000003 LDA #0
+		0x00, 0x00, 0x00,
+		0x00, 0x01, 0x00, // 000009 WORD 256
+	}
+
+	var m Machine
+	m.New()
+	m.SetSW(SWMode)
+
+	for i, b := range code {
+		m.SetByte(i, b)
+	}
+
+	if err := m.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Timer() != 256 {
+		t.Errorf("after STI: timer is %d, want 256", m.Timer())
+	}
+
+	cycles := m.Cycles()
+
+	if err := m.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := 256 - (m.Cycles() - cycles); m.Timer() != want {
+		t.Errorf("after LDA: timer is %d, want %d", m.Timer(), want)
+	}
+}
@@ -2,12 +2,8 @@ package sim
 
 import (
 	"fmt"
-	"math"
-	"os"
 )
 
-var debug bool
-
 // Opcodes
 const (
 	ADD    byte = 0x18
@@ -71,23 +67,14 @@ const (
 	WD     byte = 0xDC
 )
 
-func init() {
-	// Functions print logs if debug is true
-	_, debug = os.LookupEnv("SICSIM_DEBUG")
-}
-
-func SetDebug(debugFlag bool) {
-	debug = debugFlag
-}
-
-// isWord checks if val is a valid SIC word (24 bits)
+// isWord checks if val fits a SIC word (24 bits), either as a signed or an unsigned value
 func isWord(word int) bool {
-	return word >= -int(math.Pow(2, 24)) && word < int(math.Pow(2, 24))
+	return word >= WordMin && word <= 0xFFFFFF
 }
 
-// isAddr checks if addr is a valid SIC address
-func isAddr(addr int) bool {
-	return addr >= 0 && addr <= MAX_ADDRESS
+// isAddr checks if addr is a valid address in the machine's memory
+func (m *Machine) isAddr(addr int) bool {
+	return addr >= 0 && addr < len(m.mem)
 }
 
 // isRegister checks if reg is a valid SIC register
@@ -0,0 +1,308 @@
+package sim
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BranchCount is the number of times a conditional jump was taken and not taken
+type BranchCount struct {
+	Taken    int
+	NotTaken int
+}
+
+// Coverage records which instructions were executed and which way conditional jumps (JEQ, JGT
+// and JLT) went. Attach it to a machine with SetCoverage.
+type Coverage struct {
+	hits     map[int]int
+	branches map[int]*BranchCount
+}
+
+// NewCoverage returns an empty coverage record
+func NewCoverage() *Coverage {
+	return &Coverage{
+		hits:     make(map[int]int),
+		branches: make(map[int]*BranchCount),
+	}
+}
+
+// Coverage returns the machine's coverage record, or nil if coverage is disabled
+func (m *Machine) Coverage() *Coverage {
+	return m.coverage
+}
+
+// SetCoverage sets the coverage record of executed instructions, nil disables coverage
+func (m *Machine) SetCoverage(c *Coverage) {
+	m.coverage = c
+}
+
+// record counts the instruction at addr. A conditional jump was taken if the condition code
+// cc it was executed with matches its condition, even if it jumped to the next instruction.
+func (c *Coverage) record(addr int, opcode byte, cc int) {
+	c.hits[addr]++
+
+	if !conditionalJump(opcode) {
+		return
+	}
+
+	branch, ok := c.branches[addr]
+	if !ok {
+		branch = &BranchCount{}
+		c.branches[addr] = branch
+	}
+
+	if jumpTaken(opcode, cc) {
+		branch.Taken++
+	} else {
+		branch.NotTaken++
+	}
+}
+
+// Hits returns the number of times the instruction at addr was executed
+func (c *Coverage) Hits(addr int) int {
+	return c.hits[addr]
+}
+
+// Branch returns the number of times the conditional jump at addr was taken and not taken
+func (c *Coverage) Branch(addr int) BranchCount {
+	if branch, ok := c.branches[addr]; ok {
+		return *branch
+	}
+
+	return BranchCount{}
+}
+
+// conditionalJump reports if opcode is JEQ, JGT or JLT
+func conditionalJump(opcode byte) bool {
+	switch opcode & 0xFC {
+	case JEQ, JGT, JLT:
+		return true
+	}
+
+	return false
+}
+
+// jumpTaken reports if the conditional jump opcode jumps with the condition code cc
+func jumpTaken(opcode byte, cc int) bool {
+	switch opcode & 0xFC {
+	case JEQ:
+		return cc == EQ
+	case JGT:
+		return cc == GT
+	case JLT:
+		return cc == LT
+	}
+
+	return false
+}
+
+// coverageLine is a line of the source with the coverage of its instruction
+type coverageLine struct {
+	Num         int
+	Text        string
+	Instruction bool // The line holds an instruction
+	Hits        int
+	Jump        bool // The instruction is a conditional jump
+	Branch      BranchCount
+}
+
+// Class returns the state of the line: none, hit, miss or partial for a partly covered jump
+func (l coverageLine) Class() string {
+	switch {
+	case !l.Instruction:
+		return "none"
+	case l.Hits == 0:
+		return "miss"
+	case l.Jump && (l.Branch.Taken == 0 || l.Branch.NotTaken == 0):
+		return "partial"
+	}
+
+	return "hit"
+}
+
+// Untaken describes the direction a partly covered jump never went
+func (l coverageLine) Untaken() string {
+	if l.Class() != "partial" {
+		return ""
+	}
+
+	if l.Branch.Taken == 0 {
+		return "jump never taken"
+	}
+
+	return "jump always taken"
+}
+
+// coverageSummary is the number of covered instructions and jump directions
+type coverageSummary struct {
+	Instructions, Covered int
+	Branches, Taken       int
+}
+
+// InstructionPercent returns the percentage of executed instructions
+func (s coverageSummary) InstructionPercent() float64 {
+	return percentOf(s.Covered, s.Instructions)
+}
+
+// BranchPercent returns the percentage of jump directions that were taken
+func (s coverageSummary) BranchPercent() float64 {
+	return percentOf(s.Taken, s.Branches)
+}
+
+// percentOf returns n as a percentage of total
+func percentOf(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return 100 * float64(n) / float64(total)
+}
+
+// annotate reads the source named by m's debug info and returns its lines with their coverage.
+// Conditional jumps are recognized by their opcodes in m's memory, so jumps that never ran are
+// counted too.
+func (c *Coverage) annotate(m *Machine) ([]coverageLine, coverageSummary, error) {
+	var summary coverageSummary
+
+	info := m.DebugInfo()
+	if info == nil {
+		return nil, summary, fmt.Errorf("coverage needs the program's debug info")
+	}
+
+	data, err := os.ReadFile(info.Source)
+	if err != nil {
+		return nil, summary, fmt.Errorf("failed to read source: %w", err)
+	}
+
+	text := strings.Split(strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+	lines := make([]coverageLine, len(text))
+
+	for i := range text {
+		lines[i] = coverageLine{Num: i + 1, Text: strings.ReplaceAll(text[i], "\t", "    ")}
+	}
+
+	for _, dl := range info.Lines {
+		if !dl.Code {
+			continue
+		}
+
+		if dl.Line < 1 || dl.Line > len(lines) {
+			return nil, summary, fmt.Errorf("debug info doesn't match the source: no line %d", dl.Line)
+		}
+
+		l := &lines[dl.Line-1]
+		l.Instruction = true
+		l.Hits = c.hits[dl.Addr]
+		summary.Instructions++
+
+		if l.Hits > 0 {
+			summary.Covered++
+		}
+
+		if opcode, err := m.Byte(dl.Addr); err == nil && dl.Length >= 3 && conditionalJump(opcode) {
+			l.Jump = true
+			l.Branch = c.Branch(dl.Addr)
+			summary.Branches += 2
+
+			if l.Branch.Taken > 0 {
+				summary.Taken++
+			}
+
+			if l.Branch.NotTaken > 0 {
+				summary.Taken++
+			}
+		}
+	}
+
+	return lines, summary, nil
+}
+
+// WriteText writes the program's source with the number of times each instruction was executed.
+// Instructions that never ran are marked with #, and jumps that only went one way with !.
+func (c *Coverage) WriteText(w io.Writer, m *Machine) error {
+	lines, summary, err := c.annotate(m)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Coverage of %s:\n", m.DebugInfo().Source)
+	fmt.Fprintf(&sb, "  Instructions: %d/%d (%.2f%%)\n", summary.Covered, summary.Instructions, summary.InstructionPercent())
+	fmt.Fprintf(&sb, "  Branches:     %d/%d (%.2f%%)\n\n", summary.Taken, summary.Branches, summary.BranchPercent())
+
+	for _, l := range lines {
+		mark, count := ' ', "-"
+
+		switch l.Class() {
+		case "miss":
+			mark = '#'
+		case "partial":
+			mark = '!'
+		}
+
+		if l.Instruction {
+			count = strconv.Itoa(l.Hits)
+		}
+
+		fmt.Fprintf(&sb, "%c %8s %5d  %s", mark, count, l.Num, l.Text)
+
+		if untaken := l.Untaken(); untaken != "" {
+			fmt.Fprintf(&sb, "  <- %s", untaken)
+		}
+
+		sb.WriteByte('\n')
+	}
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// coverageHTML is the template of the HTML coverage report
+var coverageHTML = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage of {{.Source}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 0.5em; white-space: pre; }
+td.count, td.num { text-align: right; color: #666; }
+tr.hit { background: #dfd; }
+tr.miss { background: #fdd; }
+tr.partial { background: #ffc; }
+span.untaken { color: #a60; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>Coverage of {{.Source}}</h1>
+<p>Instructions: {{.Summary.Covered}}/{{.Summary.Instructions}} ({{printf "%.2f" .Summary.InstructionPercent}}%)<br>
+Branches: {{.Summary.Taken}}/{{.Summary.Branches}} ({{printf "%.2f" .Summary.BranchPercent}}%)</p>
+<table>
+{{- range .Lines}}
+<tr class="{{.Class}}"><td class="num">{{.Num}}</td><td class="count">{{if .Instruction}}{{.Hits}}{{end}}</td><td>{{.Text}}{{with .Untaken}}  <span class="untaken">{{.}}</span>{{end}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the program's source as an HTML page, highlighting executed instructions in
+// green, instructions that never ran in red and jumps that only went one way in yellow
+func (c *Coverage) WriteHTML(w io.Writer, m *Machine) error {
+	lines, summary, err := c.annotate(m)
+	if err != nil {
+		return err
+	}
+
+	return coverageHTML.Execute(w, struct {
+		Source  string
+		Summary coverageSummary
+		Lines   []coverageLine
+	}{m.DebugInfo().Source, summary, lines})
+}
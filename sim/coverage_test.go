@@ -0,0 +1,108 @@
+package sim
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erazemk/sicsim/asm"
+)
+
+// TestCoverageBranches checks that conditional jumps count as taken by their condition, even if
+// they jump to the next instruction
+func TestCoverageBranches(t *testing.T) {
+	code := []byte{
+		COMP | 0x01, 0x00, 0x00, // 000000 COMP #0, sets CC to EQ
+		JEQ | 0x03, 0x00, 0x06, // 000003 JEQ 6, taken to the next instruction
+		JGT | 0x03, 0x00, 0x00, // 000006 JGT 0, not taken
+		JLT | 0x03, 0x00, 0x00, // 000009 JLT 0, not taken
+	}
+
+	var m Machine
+	m.New()
+	m.SetCoverage(NewCoverage())
+
+	for i, b := range code {
+		m.SetByte(i, b)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := m.Execute(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[int]BranchCount{3: {Taken: 1}, 6: {NotTaken: 1}, 9: {NotTaken: 1}}
+
+	for addr, count := range want {
+		if got := m.Coverage().Branch(addr); got != count {
+			t.Errorf("jump at %06X: got %+v, want %+v", addr, got, count)
+		}
+	}
+
+	if hits := m.Coverage().Hits(0); hits != 1 {
+		t.Errorf("COMP executed %d times, want 1", hits)
+	}
+}
+
+// coveredProgram skips an instruction with a jump that is always taken
+const coveredProgram = `cov	START	0
+	LDA	#1
+	COMP	#1
+	JEQ	done
+	LDA	#2
+done	J	done
+	END	cov
+`
+
+// TestCoverageReport checks the text report of an assembled program, whose lines come from its
+// debug info
+func TestCoverageReport(t *testing.T) {
+	dir := t.TempDir()
+	src, obj := filepath.Join(dir, "cov.asm"), filepath.Join(dir, "cov.obj")
+
+	if err := os.WriteFile(src, []byte(coveredProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := asm.AssembleFile(src, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	var m Machine
+	m.New()
+	m.SetCoverage(NewCoverage())
+
+	var report strings.Builder
+
+	if err := m.Coverage().WriteText(&report, &m); err == nil {
+		t.Error("wrote a report without debug info")
+	}
+
+	if err := m.ParseObjFile(obj); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Run(context.Background(), Limits{Steps: 100})
+
+	if err := m.Coverage().WriteText(&report, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"  Instructions: 4/5 (80.00%)",
+		"  Branches:     1/2 (50.00%)",
+		"         -     1  cov    START    0",
+		"         1     2      LDA    #1",
+		"!        1     4      JEQ    done  <- jump always taken",
+		"#        0     5      LDA    #2",
+	}
+
+	for _, line := range want {
+		if !strings.Contains(report.String(), line+"\n") {
+			t.Errorf("report doesn't contain %q:\n%s", line, report.String())
+		}
+	}
+}
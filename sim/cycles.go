@@ -0,0 +1,69 @@
+package sim
+
+// Timing holds the cycle costs used to count machine cycles.
+// The defaults are not taken from real hardware, but keep the relative cost of instructions sensible.
+type Timing struct {
+	Format       [5]int       // Base cost by instruction format (1-4), index 0 is the SIC format
+	Opcodes      map[byte]int // Additional cost of individual opcodes
+	Indirect     int          // Cost of resolving an indirect address
+	MemoryAccess int          // Cost of each memory operand read or written
+	DeviceIO     int          // Cost of waiting for a device during RD, WD and TD
+	ChannelByte  int          // Cycles an I/O channel needs to move a byte, 0 moves blocks at once
+}
+
+// DefaultTiming returns the cycle costs used by new machines
+func DefaultTiming() Timing {
+	return Timing{
+		Format:       [5]int{3, 1, 2, 3, 4},
+		Indirect:     1,
+		MemoryAccess: 1,
+		DeviceIO:     10,
+		ChannelByte:  2,
+		Opcodes: map[byte]int{
+			MUL:  4,
+			MULR: 4,
+			DIV:  10,
+			DIVR: 10,
+		},
+	}
+}
+
+// Timing returns the machine's cycle costs
+func (m *Machine) Timing() Timing {
+	return m.timing
+}
+
+// SetTiming sets the machine's cycle costs
+func (m *Machine) SetTiming(timing Timing) {
+	m.timing = timing
+}
+
+// Cycles returns the number of machine cycles used so far
+func (m *Machine) Cycles() int {
+	return m.cycles
+}
+
+// ResetCycles sets the cycle counter back to 0
+func (m *Machine) ResetCycles() {
+	m.cycles = 0
+
+	for i := range m.busy {
+		m.busy[i].cycles = 0
+	}
+}
+
+// charge adds the cost of an executed instruction to the cycle counter
+func (m *Machine) charge(format int, opcode byte, memory int, indirect bool) {
+	cost := m.timing.Format[format] + m.timing.Opcodes[opcode] + memory*m.timing.MemoryAccess
+
+	if indirect {
+		cost += m.timing.Indirect
+	}
+
+	switch opcode {
+	case RD, WD, TD:
+		cost += m.timing.DeviceIO
+	}
+
+	m.cycles += cost
+}
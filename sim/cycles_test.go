@@ -0,0 +1,72 @@
+package sim
+
+import "testing"
+
+// TestCycles checks the cycles charged per instruction format, operand and opcode
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   []byte
+		cycles int
+	}{
+		{"format 1", []byte{TIO}, 1},
+		{"format 2", []byte{CLEAR, 0x00}, 2},
+		{"immediate", []byte{LDA | 0x01, 0x00, 0x01}, 3},
+		{"simple", []byte{LDA | 0x03, 0x00, 0x30}, 4},
+		{"indirect", []byte{LDA | 0x02, 0x00, 0x30}, 6},
+		{"format 4", []byte{LDA | 0x01, 0x10, 0x00, 0x01}, 4},
+		{"SIC format", []byte{LDA, 0x00, 0x30}, 4},
+		{"store", []byte{STA | 0x03, 0x00, 0x30}, 4},
+		{"jump", []byte{J | 0x03, 0x00, 0x30}, 3},
+		{"opcode cost", []byte{MUL | 0x01, 0x00, 0x02}, 7},
+		{"device", []byte{TD | 0x01, 0x00, 0x05}, 13},
+	}
+
+	for _, tt := range tests {
+		var m Machine
+		m.New()
+		m.SetDeviceDir(t.TempDir())
+
+		for i, b := range tt.code {
+			m.SetByte(i, b)
+		}
+
+		if err := m.Execute(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if m.Cycles() != tt.cycles {
+			t.Errorf("%s: %d cycles, want %d", tt.name, m.Cycles(), tt.cycles)
+		}
+	}
+}
+
+// TestCustomTiming checks that a machine charges the cycle costs it was given
+func TestCustomTiming(t *testing.T) {
+	var m Machine
+	m.New()
+
+	timing := DefaultTiming()
+	timing.Format[3] = 5
+	timing.Opcodes = map[byte]int{LDA: 2}
+	m.SetTiming(timing)
+
+	for i, b := range []byte{LDA | 0x01, 0x00, 0x01} {
+		m.SetByte(i, b)
+	}
+
+	if err := m.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Cycles() != 7 {
+		t.Errorf("%d cycles, want 7", m.Cycles())
+	}
+
+	m.ResetCycles()
+
+	if m.Cycles() != 0 {
+		t.Errorf("%d cycles after reset", m.Cycles())
+	}
+}
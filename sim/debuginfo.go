@@ -0,0 +1,112 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/erazemk/sicsim/debuginfo"
+)
+
+// DebugInfo returns the debug info of the loaded program, or nil if it has none
+func (m *Machine) DebugInfo() *debuginfo.Info {
+	return m.debugInfo
+}
+
+// SetDebugInfo sets the debug info of the loaded program and replaces the symbol table with its symbols
+func (m *Machine) SetDebugInfo(info *debuginfo.Info) {
+	m.debugInfo = info
+
+	if info != nil {
+		m.SetSymbols(info.SymbolTable())
+	}
+}
+
+// LoadDebugInfo reads the debug info file written by sicasm for the object file objFile.
+// It's not an error if the file doesn't exist.
+func (m *Machine) LoadDebugInfo(objFile string) error {
+	info, err := readDebugInfo(objFile)
+	if err != nil || info == nil {
+		return err
+	}
+
+	m.setLoadedDebugInfo(info, debuginfo.Path(objFile))
+	return nil
+}
+
+// loadObjDebugInfo loads the debug info of the object file objFile, whose header record has the
+// program's name, start and length. Debug info that can't be read or describes another program
+// is ignored with a warning, as the program runs fine without it.
+func (m *Machine) loadObjDebugInfo(objFile, name string, start, length int) {
+	info, err := readDebugInfo(objFile)
+	if err == nil && info != nil {
+		err = checkDebugInfo(info, name, start, length)
+	}
+
+	if err != nil {
+		m.logger.Printf("Ignoring debug info: %v\n", err)
+		return
+	}
+
+	if info != nil {
+		m.setLoadedDebugInfo(info, debuginfo.Path(objFile))
+	}
+}
+
+// readDebugInfo reads the debug info file of the object file objFile, returning nil if it doesn't exist
+func readDebugInfo(objFile string) (*debuginfo.Info, error) {
+	path := debuginfo.Path(objFile)
+
+	info, err := debuginfo.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+
+	return info, nil
+}
+
+// checkDebugInfo checks that the debug info describes the program with the header record's
+// name, start and length, which only holds the first 6 characters of the name
+func checkDebugInfo(info *debuginfo.Info, name string, start, length int) error {
+	program := info.Program
+	if len(program) > len(name) {
+		program = program[:len(name)]
+	}
+
+	if strings.TrimSpace(program) != strings.TrimSpace(name) || info.Start != start || info.Length != length {
+		return fmt.Errorf("debug info is for %s at %06X (%d bytes), not %s at %06X (%d bytes)",
+			info.Program, info.Start, info.Length, strings.TrimSpace(name), start, length)
+	}
+
+	return nil
+}
+
+// setLoadedDebugInfo sets the debug info read from path
+func (m *Machine) setLoadedDebugInfo(info *debuginfo.Info, path string) {
+	m.SetDebugInfo(info)
+
+	if m.debug {
+		m.logger.Printf("Loaded debug info from %s (%d symbols, %d lines)\n", path, len(info.Symbols), len(info.Lines))
+	}
+}
+
+// SourceLine returns the source line of the instruction or data item containing addr
+func (m *Machine) SourceLine(addr int) (int, bool) {
+	if m.debugInfo == nil {
+		return 0, false
+	}
+
+	return m.debugInfo.LineOf(addr)
+}
+
+// LineAddr returns the address of the first instruction or data item on a source line
+func (m *Machine) LineAddr(line int) (int, bool) {
+	if m.debugInfo == nil {
+		return 0, false
+	}
+
+	return m.debugInfo.AddrOf(line)
+}
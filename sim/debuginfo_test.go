@@ -0,0 +1,78 @@
+package sim
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erazemk/sicsim/debuginfo"
+)
+
+// TestObjDebugInfo checks that only debug info matching the object file's header is loaded
+func TestObjDebugInfo(t *testing.T) {
+	info := func(program string, start, length int) *debuginfo.Info {
+		return &debuginfo.Info{Program: program, Start: start, Length: length, Symbols: []debuginfo.Symbol{{Name: "VAL", Value: 0x103, Kind: debuginfo.KindLabel}}}
+	}
+
+	tests := []struct {
+		name    string
+		info    *debuginfo.Info // Debug info to write, nil to write raw
+		raw     string
+		loaded  bool
+		warning string
+	}{
+		{"matching", info("PROGRA", 0x100, 6), "", true, ""},
+		{"long name", info("PROGRAM", 0x100, 6), "", true, ""}, // The header only holds 6 characters
+		{"no debug info", nil, "", false, ""},
+		{"malformed", nil, "{\"program\": ", false, "Ignoring debug info"},
+		{"other program", info("PROG", 0x100, 6), "", false, "not PROGRA"},
+		{"other start", info("PROGRA", 0, 6), "", false, "not PROGRA at 000100"},
+		{"other length", info("PROGRA", 0x100, 9), "", false, "(6 bytes)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := filepath.Join(t.TempDir(), "prog.obj")
+			code := "HPROGRA000100000006\nT00010006000000000007\nE000100\n"
+
+			if err := os.WriteFile(obj, []byte(code), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.info != nil {
+				if err := tt.info.Write(debuginfo.Path(obj)); err != nil {
+					t.Fatal(err)
+				}
+			} else if tt.raw != "" {
+				if err := os.WriteFile(debuginfo.Path(obj), []byte(tt.raw), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var m Machine
+			var logs bytes.Buffer
+
+			m.New()
+			m.SetLogger(log.New(&logs, "", 0))
+
+			if err := m.ParseObjFile(obj); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := m.Symbols()["VAL"]; ok != tt.loaded {
+				t.Errorf("debug info loaded: %v, want %v", ok, tt.loaded)
+			}
+
+			if tt.warning == "" && logs.Len() > 0 || !strings.Contains(logs.String(), tt.warning) {
+				t.Errorf("logged %q, want %q", logs.String(), tt.warning)
+			}
+
+			if word, _ := m.Word(0x103); word != 7 {
+				t.Errorf("program wasn't loaded, word at 000103 is %d", word)
+			}
+		})
+	}
+}
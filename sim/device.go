@@ -2,101 +2,404 @@ package sim
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
 )
 
-type device struct {
-	num    byte
-	name   string
-	reader *bufio.Reader
-	writer *bufio.Writer
+// Device is a SIC device, addressed by the TD, RD and WD instructions
+type Device interface {
+	Test() bool          // Reports if the device is ready
+	Read() (byte, error) // Reads a byte, returns io.EOF once there is no more input (see EOFReporter)
+	Write(byte) error    // Writes a byte
 }
 
-// NewDevice creates a new device
-func newDevice(id byte) (*device, error) {
-	dev := device{num: id}
+// Resetter is implemented by devices that can be reset to their initial state
+type Resetter interface {
+	Reset() error
+}
 
-	switch id {
-	case 0:
-		dev.name = "stdin"
-		dev.reader = bufio.NewReader(os.Stdin)
-	case 1:
-		dev.name = "stdout"
-		dev.writer = bufio.NewWriter(os.Stdout)
-	case 2:
-		dev.name = "stderr"
-		dev.writer = bufio.NewWriter(os.Stderr)
-	default:
-		dev.name = fmt.Sprintf("%02X.dev", dev.num)
+// streamDevice adapts an io.Reader and/or an io.Writer to a Device. So that TD doesn't wait for
+// input, such as from stdin, a byte is read in the background when TD or RD asks for one, and
+// never more than the program asked for.
+type streamDevice struct {
+	reader io.Reader
+	writer io.Writer
+	start  sync.Once
 
-		infd, err := os.OpenFile(dev.name, os.O_APPEND|os.O_CREATE|os.O_RDONLY, 0644)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create input device: %w", err)
+	mu      sync.Mutex
+	cond    *sync.Cond
+	next    byte  // Byte read in the background, valid if ready is set
+	ready   bool  // A byte was read and RD hasn't taken it yet
+	wanted  bool  // TD or RD asked for a byte, which the background reader hasn't read yet
+	err     error // Set once the reader ended or failed
+	closed  bool  // The device was closed, the background reader stops
+	reading bool  // The device was last used by RD
+}
+
+// NewReaderDevice returns a read-only device reading from r
+func NewReaderDevice(r io.Reader) Device {
+	return newStreamDevice(r, nil)
+}
+
+// NewWriterDevice returns a write-only device writing to w
+func NewWriterDevice(w io.Writer) Device {
+	return newStreamDevice(nil, w)
+}
+
+// NewReadWriterDevice returns a device reading from r and writing to w
+func NewReadWriterDevice(r io.Reader, w io.Writer) Device {
+	return newStreamDevice(r, w)
+}
+
+func newStreamDevice(r io.Reader, w io.Writer) *streamDevice {
+	d := &streamDevice{reader: r, writer: w, reading: w == nil}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// request asks the background reader for the next byte, if none is read or being read.
+// d.mu must be held.
+func (d *streamDevice) request() {
+	if d.ready || d.wanted || d.err != nil || d.closed {
+		return
+	}
+
+	d.wanted = true
+	d.start.Do(func() { go d.readAhead() })
+	d.cond.Broadcast()
+}
+
+// readAhead reads a byte each time one is requested, until the reader ends or the device is closed
+func (d *streamDevice) readAhead() {
+	var b [1]byte
+
+	for {
+		d.mu.Lock()
+
+		for !d.wanted && !d.closed {
+			d.cond.Wait()
+		}
+
+		if d.closed {
+			d.mu.Unlock()
+			return
 		}
 
-		dev.reader = bufio.NewReader(infd)
+		d.mu.Unlock()
 
-		outfd, err := os.OpenFile(dev.name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create output device: %w", err)
+		n, err := d.reader.Read(b[:])
+
+		d.mu.Lock()
+		d.wanted = false
+
+		if n > 0 {
+			d.next, d.ready = b[0], true
+		} else if err != nil {
+			d.err = err
 		}
 
-		dev.writer = bufio.NewWriter(outfd)
+		d.cond.Broadcast()
+		done := d.err != nil
+		d.mu.Unlock()
+
+		if done {
+			return
+		}
 	}
+}
 
-	if debug {
-		log.Println("Added new device:", dev.name)
+// Test reports if the device is ready for RD after it was last read from, and for WD otherwise.
+// It is ready for RD once the next byte was read or the reader failed, so RD won't wait.
+func (d *streamDevice) Test() bool {
+	if d.reader == nil {
+		return d.writer != nil
 	}
 
-	return &dev, nil
+	d.mu.Lock()
+
+	if !d.reading {
+		d.mu.Unlock()
+		return true
+	}
+
+	d.request()
+	ready := d.ready || d.err != nil && d.err != io.EOF
+	d.mu.Unlock()
+
+	if !ready {
+		// Programs poll TD in a loop, let the reader run in the meantime
+		runtime.Gosched()
+	}
+
+	return ready
 }
 
-// test checks if a device is available for reading or writing
-func (d *device) test() bool {
-	return d.reader != nil && d.writer != nil
+// EOF reports if the device was last read from and its reader ended, without waiting for input
+func (d *streamDevice) EOF() bool {
+	if d.reader == nil {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.reading {
+		return false
+	}
+
+	d.request()
+	return !d.ready && d.err == io.EOF
 }
 
-// read reads a byte from device
-func (d *device) read() (byte, error) {
+// Read returns the next byte, waiting for one if it wasn't read yet
+func (d *streamDevice) Read() (byte, error) {
 	if d.reader == nil {
-		return 0, fmt.Errorf("failed to open device '%s' for reading", d.name)
+		return 0, fmt.Errorf("device is not readable")
 	}
 
-	val, err := d.reader.ReadByte()
-	if err != nil {
-		if err == io.EOF {
-			val = 0
-		} else {
-			return val, fmt.Errorf("failed to read from device '%s': %w", d.name, err)
-		}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.reading = true
+
+	for !d.ready && d.err == nil && !d.closed {
+		d.request()
+		d.cond.Wait()
+	}
+
+	if d.ready {
+		d.ready = false
+		return d.next, nil
 	}
 
-	if debug {
-		log.Printf("Read byte '%c' from device '%s'\n", val, d.name)
+	if d.closed {
+		return 0, fmt.Errorf("device is closed")
 	}
 
-	return val, nil
+	return 0, d.err
 }
 
-// write writes a byte to device
-func (d *device) write(val byte) error {
+func (d *streamDevice) Write(val byte) error {
 	if d.writer == nil {
-		return fmt.Errorf("failed to open device '%s' for writing", d.name)
+		return fmt.Errorf("device is not writable")
 	}
 
-	err := d.writer.WriteByte(val)
-	if err != nil {
-		return fmt.Errorf("failed to write to device '%s': %w", d.name, err)
+	d.mu.Lock()
+	d.reading = false
+	d.mu.Unlock()
+
+	_, err := d.writer.Write([]byte{val})
+	return err
+}
+
+// Close stops the background reader. A read it already started still completes, but its byte is
+// never used. The reader and writer aren't closed.
+func (d *streamDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	d.cond.Broadcast()
+	return nil
+}
+
+// BufferDevice is an in-memory device, which reads from a fixed input and collects its output
+type BufferDevice struct {
+	input  []byte
+	pos    int
+	output bytes.Buffer
+}
+
+// NewBufferDevice returns an in-memory device that reads input
+func NewBufferDevice(input []byte) *BufferDevice {
+	return &BufferDevice{input: input}
+}
+
+func (d *BufferDevice) Test() bool {
+	return true
+}
+
+func (d *BufferDevice) EOF() bool {
+	return d.pos >= len(d.input)
+}
+
+func (d *BufferDevice) Read() (byte, error) {
+	if d.pos >= len(d.input) {
+		return 0, io.EOF
 	}
 
-	d.writer.Flush()
+	d.pos++
+	return d.input[d.pos-1], nil
+}
+
+func (d *BufferDevice) Write(val byte) error {
+	return d.output.WriteByte(val)
+}
+
+// Reset rewinds the input and discards the output
+func (d *BufferDevice) Reset() error {
+	d.pos = 0
+	d.output.Reset()
+	return nil
+}
+
+// Output returns the bytes written to the device
+func (d *BufferDevice) Output() []byte {
+	return d.output.Bytes()
+}
+
+// FileDevice reads from and writes to files, which are opened on first use
+type FileDevice struct {
+	input  string // Path of the input file, empty if the device is not readable
+	output string // Path of the output file, empty if the device is not writable
+	flag   int    // Additional flags used to open the output file
+	create bool   // Create the input file if it doesn't exist, as default devices do
+
+	in      *os.File
+	reader  *bufio.Reader
+	out     *os.File
+	reading bool // The device was last used by RD, or has no output file
+}
+
+// NewFileDevice returns a device reading from the file input and writing to the file output.
+// Either path may be empty, and both may be the same file. The output file is created if it
+// doesn't exist and is either appended to or truncated when it is opened.
+func NewFileDevice(input, output string, appendOutput bool) *FileDevice {
+	flag := os.O_TRUNC
+	if appendOutput {
+		flag = os.O_APPEND
+	}
+
+	return &FileDevice{input: input, output: output, flag: flag, reading: output == ""}
+}
+
+func (d *FileDevice) Test() bool {
+	return d.input != "" || d.output != ""
+}
 
-	if debug {
-		log.Printf("Wrote byte '%c' to device '%s'\n", val, d.name)
+// EOF reports if the device was last read from and its input file has no more input. Only regular
+// files are checked, as peeking a FIFO or a character device would wait for input. Devices without
+// an input file and input files that can't be opened don't report EOF, reading from them fails
+// instead.
+func (d *FileDevice) EOF() bool {
+	if d.input == "" || !d.reading {
+		return false
 	}
 
+	if d.in == nil {
+		if info, err := os.Stat(d.input); err != nil || !info.Mode().IsRegular() {
+			return false
+		}
+	} else if info, err := d.in.Stat(); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	if d.open() != nil {
+		return false
+	}
+
+	_, err := d.reader.Peek(1)
+	return err == io.EOF
+}
+
+func (d *FileDevice) Read() (byte, error) {
+	if d.input == "" {
+		return 0, fmt.Errorf("device is not readable")
+	}
+
+	if err := d.open(); err != nil {
+		return 0, err
+	}
+
+	d.reading = true
+	return d.reader.ReadByte()
+}
+
+// open opens the input file, if it isn't open yet
+func (d *FileDevice) open() error {
+	if d.in != nil {
+		return nil
+	}
+
+	flag := os.O_RDONLY
+	if d.create {
+		flag |= os.O_CREATE
+	}
+
+	in, err := os.OpenFile(d.input, flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	d.in = in
+	d.reader = bufio.NewReader(in)
 	return nil
 }
+
+func (d *FileDevice) Write(val byte) error {
+	if d.output == "" {
+		return fmt.Errorf("device is not writable")
+	}
+
+	if d.out == nil {
+		out, err := os.OpenFile(d.output, os.O_CREATE|os.O_WRONLY|d.flag, 0644)
+		if err != nil {
+			return err
+		}
+
+		d.out = out
+	}
+
+	d.reading = false
+	_, err := d.out.Write([]byte{val})
+	return err
+}
+
+// Reset closes the files, so they are opened again (and the output truncated) on next use
+func (d *FileDevice) Reset() error {
+	d.reading = d.output == ""
+	return d.Close()
+}
+
+// Close closes the device's open files
+func (d *FileDevice) Close() error {
+	var err error
+
+	if d.in != nil {
+		err = d.in.Close()
+		d.in, d.reader = nil, nil
+	}
+
+	if d.out != nil {
+		if cerr := d.out.Close(); err == nil {
+			err = cerr
+		}
+
+		d.out = nil
+	}
+
+	return err
+}
+
+// newDevice creates the default device for id: the machine's standard streams for devices 0-2,
+// and the file XX.dev in the machine's device directory for all other devices
+func (m *Machine) newDevice(id byte) Device {
+	switch id {
+	case 0:
+		return NewReaderDevice(m.stdin)
+	case 1:
+		return NewWriterDevice(m.stdout)
+	case 2:
+		return NewWriterDevice(m.stderr)
+	}
+
+	path := filepath.Join(m.devDir, fmt.Sprintf("%02X.dev", id))
+	dev := NewFileDevice(path, path, true)
+	dev.create = true
+	return dev
+}
@@ -0,0 +1,302 @@
+package sim
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// readAll reads from dev until it reports the end of input
+func readAll(t *testing.T, dev Device) string {
+	t.Helper()
+	var sb strings.Builder
+
+	for {
+		val, err := dev.Read()
+		if err == io.EOF {
+			return sb.String()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		sb.WriteByte(val)
+	}
+}
+
+// TestBufferDevice checks reading, writing and resetting an in-memory device
+func TestBufferDevice(t *testing.T) {
+	d := NewBufferDevice([]byte("ab"))
+
+	if !d.Test() || d.EOF() {
+		t.Fatal("device with input isn't ready")
+	}
+
+	if got := readAll(t, d); got != "ab" {
+		t.Errorf("read %q, want \"ab\"", got)
+	}
+
+	if !d.EOF() {
+		t.Error("device doesn't report the end of input")
+	}
+
+	for _, b := range []byte("xy") {
+		if err := d.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := string(d.Output()); got != "xy" {
+		t.Errorf("output %q, want \"xy\"", got)
+	}
+
+	d.Reset()
+
+	if d.EOF() || len(d.Output()) > 0 {
+		t.Error("reset didn't rewind the input and discard the output")
+	}
+}
+
+// TestStreamDevice checks devices adapting readers and writers
+func TestStreamDevice(t *testing.T) {
+	var out bytes.Buffer
+
+	r := NewReaderDevice(strings.NewReader("abc"))
+	w := NewWriterDevice(&out)
+	rw := NewReadWriterDevice(strings.NewReader(""), &out)
+
+	for _, d := range []Device{r, w, rw} {
+		waitFor(t, "the device to be ready", d.Test)
+	}
+
+	if rw.(EOFReporter).EOF() {
+		t.Error("device that wasn't read from reports the end of input")
+	}
+
+	if got := readAll(t, r); got != "abc" {
+		t.Errorf("read %q, want \"abc\"", got)
+	}
+
+	if got := readAll(t, rw); got != "" {
+		t.Errorf("read %q, want nothing", got)
+	}
+
+	for _, d := range []Device{r, rw} {
+		waitFor(t, "the end of input", d.(EOFReporter).EOF)
+	}
+
+	if w.(EOFReporter).EOF() {
+		t.Error("write-only device reports the end of input")
+	}
+
+	if _, err := w.Read(); err == nil {
+		t.Error("reading from a write-only device succeeded")
+	}
+
+	if err := r.Write('x'); err == nil {
+		t.Error("writing to a read-only device succeeded")
+	}
+
+	w.Write('1')
+	rw.Write('2')
+
+	if got := out.String(); got != "12" {
+		t.Errorf("output %q, want \"12\"", got)
+	}
+}
+
+// TestStreamDeviceTD checks that TD on a stream doesn't wait for input
+func TestStreamDeviceTD(t *testing.T) {
+	pr, pw := io.Pipe()
+
+	var m Machine
+	m.New()
+	m.AttachDevice(0, NewReaderDevice(pr))
+
+	if state := m.DeviceState(0); state != DeviceBusy {
+		t.Errorf("without input: got %v, want %v", state, DeviceBusy)
+	}
+
+	go func() {
+		pw.Write([]byte("x"))
+		pw.Close()
+	}()
+
+	waitFor(t, "input", func() bool { return m.DeviceState(0) == DeviceReady })
+
+	if val, err := m.ReadDevice(0); val != 'x' || err != nil {
+		t.Errorf("read %q, %v, want 'x'", val, err)
+	}
+
+	waitFor(t, "the end of input", func() bool { return m.DeviceState(0) == DeviceEOF })
+}
+
+// countingReader counts the reads from its reader
+type countingReader struct {
+	r     io.Reader
+	reads int32
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	atomic.AddInt32(&c.reads, 1)
+	return c.r.Read(p)
+}
+
+// TestStreamDeviceOnDemand checks that a stream is only read when TD or RD asks for a byte, so
+// input following what the program read, such as debugger commands, is left alone
+func TestStreamDeviceOnDemand(t *testing.T) {
+	in := &countingReader{r: strings.NewReader("ab")}
+	d := NewReaderDevice(in)
+
+	time.Sleep(10 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&in.reads); n != 0 {
+		t.Errorf("unused device read %d times", n)
+	}
+
+	waitFor(t, "the device to be ready", d.Test)
+
+	if val, err := d.Read(); val != 'a' || err != nil {
+		t.Fatalf("read %q, %v, want 'a'", val, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&in.reads); n != 1 {
+		t.Errorf("device read %d times after reading a byte, want 1", n)
+	}
+
+	if rest, _ := io.ReadAll(in.r); string(rest) != "b" {
+		t.Errorf("device consumed input it wasn't asked for, %q is left", rest)
+	}
+}
+
+// TestStreamDeviceClose checks that closing a device stops a read waiting for input
+func TestStreamDeviceClose(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	d := NewReaderDevice(pr)
+	done := make(chan error)
+
+	go func() {
+		_, err := d.Read()
+		done <- err
+	}()
+
+	d.(io.Closer).Close()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("reading a closed device succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't stop the read")
+	}
+}
+
+// TestFileDeviceEOF checks that only regular files that were read from report the end of input
+func TestFileDeviceEOF(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty")
+
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !NewFileDevice(empty, "", false).EOF() {
+		t.Error("empty input file doesn't report the end of input")
+	}
+
+	if NewFileDevice(empty, empty, true).EOF() {
+		t.Error("device that wasn't read from reports the end of input")
+	}
+
+	if NewFileDevice(os.DevNull, "", false).EOF() {
+		t.Error("character device reports the end of input")
+	}
+}
+
+// TestFileDevice checks reading from and truncating or appending to files
+func TestFileDevice(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+
+	if err := os.WriteFile(in, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(out, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewFileDevice(in, out, false)
+	defer d.Close()
+
+	if got := readAll(t, d); got != "data" || !d.EOF() {
+		t.Errorf("read %q, want \"data\" and the end of input", got)
+	}
+
+	d.Write('x')
+	d.Reset()
+	d.Write('y')
+	d.Close()
+
+	if got, _ := os.ReadFile(out); string(got) != "y" {
+		t.Errorf("truncated output %q, want \"y\"", got)
+	}
+
+	d = NewFileDevice("", out, true)
+	d.Write('z')
+	d.Close()
+
+	if got, _ := os.ReadFile(out); string(got) != "yz" {
+		t.Errorf("appended output %q, want \"yz\"", got)
+	}
+
+	if _, err := d.Read(); err == nil {
+		t.Error("reading from a device without an input file succeeded")
+	}
+
+	if _, err := NewFileDevice(filepath.Join(dir, "missing"), "", false).Read(); err == nil {
+		t.Error("reading a missing input file succeeded")
+	}
+}
+
+// TestDefaultFileDevice checks that reading from a default device creates its XX.dev file, and
+// that it only reports the end of input once it was read from
+func TestDefaultFileDevice(t *testing.T) {
+	var m Machine
+	m.New()
+	m.SetDeviceDir(t.TempDir())
+	defer m.Close()
+
+	if state := m.DeviceState(0x05); state != DeviceReady {
+		t.Errorf("unused device is %s, want %s", state, DeviceReady)
+	}
+
+	if _, err := m.ReadDevice(0x05); !errors.Is(err, io.EOF) {
+		t.Errorf("reading an empty device: got %v, want io.EOF", err)
+	}
+
+	if state := m.DeviceState(0x05); state != DeviceEOF {
+		t.Errorf("empty device is %s after reading, want %s", state, DeviceEOF)
+	}
+
+	if _, err := os.Stat(filepath.Join(m.devDir, "05.dev")); err != nil {
+		t.Error(err)
+	}
+
+	if err := m.WriteDevice(0x06, 'a'); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := os.ReadFile(filepath.Join(m.devDir, "06.dev")); string(got) != "a" {
+		t.Errorf("output %q, want \"a\"", got)
+	}
+}
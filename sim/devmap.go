@@ -0,0 +1,282 @@
+package sim
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DeviceMapping describes how a device is backed by files.
+//
+// Mappings are written as ID=path[:mode], where ID is the device number in hex and mode is one of:
+//
+//	r   read from path
+//	w   write to path, truncating it first
+//	a   append to path
+//	rw  read from and append to path (default)
+//
+// The path "missing" maps a device that is never ready and can't be read or written, "clock" maps
+// the machine's clock (see NewClockDevice) and "timer" its interval timer (see NewTimerDevice).
+//
+// Instead of a path, a device can be connected to a socket, which is used for both reading and
+// writing:
+//
+//	tcp:host:port           connect to a TCP listener
+//	tcp-listen:[host]:port  listen for a TCP peer
+//	unix:path               connect to a Unix domain socket
+//	unix-listen:path        listen for a peer on a Unix domain socket
+//
+// The path disk:image maps a disk device backed by a disk image, see DiskDevice.
+type DeviceMapping struct {
+	ID      byte
+	Input   string // File read by RD, empty if the mapping doesn't provide input
+	Output  string // File written by WD, empty if the mapping doesn't provide output
+	Append  bool   // Append to the output file instead of truncating it
+	Missing bool   // The device is not connected
+	Network string // Socket network (tcp or unix), empty if the device isn't a socket
+	Address string // Socket address
+	Listen  bool   // Listen for a peer instead of connecting to one
+	Disk    string // Disk image, empty if the device isn't a disk
+	Clock   bool   // The device is the machine's clock
+	Timer   bool   // The device controls the machine's interval timer
+}
+
+// missingDevice is a device that is not connected
+type missingDevice struct{}
+
+// NewMissingDevice returns a device that is never ready and fails every read and write
+func NewMissingDevice() Device {
+	return missingDevice{}
+}
+
+func (missingDevice) Test() bool {
+	return false
+}
+
+func (missingDevice) Read() (byte, error) {
+	return 0, fmt.Errorf("device is not connected")
+}
+
+func (missingDevice) Write(byte) error {
+	return fmt.Errorf("device is not connected")
+}
+
+// ParseDeviceMapping parses a mapping in the form ID=path[:mode]
+func ParseDeviceMapping(spec string) (DeviceMapping, error) {
+	var dm DeviceMapping
+
+	eq := strings.IndexRune(spec, '=')
+	if eq < 0 {
+		return dm, fmt.Errorf("invalid device mapping '%s': expected ID=path[:mode]", spec)
+	}
+
+	id, err := strconv.ParseUint(strings.TrimSpace(spec[:eq]), 16, 8)
+	if err != nil {
+		return dm, fmt.Errorf("invalid device ID in mapping '%s': %w", spec, err)
+	}
+
+	dm.ID = byte(id)
+	path, mode := strings.TrimSpace(spec[eq+1:]), "rw"
+
+	if strings.HasPrefix(path, "disk:") {
+		if dm.Disk = strings.TrimPrefix(path, "disk:"); dm.Disk == "" {
+			return dm, fmt.Errorf("invalid device mapping '%s': missing disk image", spec)
+		}
+
+		return dm, nil
+	}
+
+	if network, address, listen, ok := parseSocket(path); ok {
+		if address == "" {
+			return dm, fmt.Errorf("invalid device mapping '%s': missing socket address", spec)
+		}
+
+		dm.Network, dm.Address, dm.Listen = network, address, listen
+		return dm, nil
+	}
+
+	// The mode is optional, so only treat the text after the last colon as a mode if it is one
+	if colon := strings.LastIndex(path, ":"); colon >= 0 {
+		switch path[colon+1:] {
+		case "r", "w", "a", "rw":
+			path, mode = path[:colon], path[colon+1:]
+		}
+	}
+
+	if path == "" {
+		return dm, fmt.Errorf("invalid device mapping '%s': missing path", spec)
+	}
+
+	switch path {
+	case "missing":
+		dm.Missing = true
+		return dm, nil
+	case "clock":
+		dm.Clock = true
+		return dm, nil
+	case "timer":
+		dm.Timer = true
+		return dm, nil
+	}
+
+	switch mode {
+	case "r":
+		dm.Input = path
+	case "w":
+		dm.Output = path
+	case "a":
+		dm.Output = path
+		dm.Append = true
+	case "rw":
+		dm.Input = path
+		dm.Output = path
+		dm.Append = true
+	}
+
+	return dm, nil
+}
+
+// parseSocket splits a socket mapping into its network and address
+func parseSocket(path string) (network, address string, listen, ok bool) {
+	colon := strings.IndexRune(path, ':')
+	if colon < 0 {
+		return "", "", false, false
+	}
+
+	network, address = path[:colon], path[colon+1:]
+
+	if strings.HasSuffix(network, "-listen") {
+		network, listen = strings.TrimSuffix(network, "-listen"), true
+	}
+
+	if network != "tcp" && network != "unix" {
+		return "", "", false, false
+	}
+
+	return network, address, listen, true
+}
+
+// LoadDeviceConfig reads device mappings from a file with one mapping per line.
+// Empty lines and lines starting with # are ignored.
+func LoadDeviceConfig(path string) ([]DeviceMapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open device config: %w", err)
+	}
+
+	defer file.Close()
+
+	var mappings []DeviceMapping
+	sc := bufio.NewScanner(file)
+
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		dm, err := ParseDeviceMapping(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		mappings = append(mappings, dm)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read device config: %w", err)
+	}
+
+	return mappings, nil
+}
+
+// MapDevices attaches the devices described by mappings. Mappings of the same device are merged,
+// so a device can read from one file and write to another.
+func (m *Machine) MapDevices(mappings []DeviceMapping) error {
+	merged := make(map[byte]*DeviceMapping)
+	var order []byte
+
+	for _, dm := range mappings {
+		prev, ok := merged[dm.ID]
+		if !ok {
+			dm := dm
+			merged[dm.ID] = &dm
+			order = append(order, dm.ID)
+			continue
+		}
+
+		if prev.Missing || dm.Missing {
+			return fmt.Errorf("device %02X is mapped as missing and to a file", dm.ID)
+		}
+
+		if prev.Network != "" || dm.Network != "" {
+			return fmt.Errorf("device %02X is mapped to a socket and to another backing", dm.ID)
+		}
+
+		if prev.Disk != "" || dm.Disk != "" {
+			return fmt.Errorf("device %02X is mapped to a disk and to another backing", dm.ID)
+		}
+
+		if prev.Clock || dm.Clock || prev.Timer || dm.Timer {
+			return fmt.Errorf("device %02X is mapped to the clock or timer and to another backing", dm.ID)
+		}
+
+		if prev.Input != "" && dm.Input != "" {
+			return fmt.Errorf("device %02X has more than one input file", dm.ID)
+		}
+
+		if prev.Output != "" && dm.Output != "" {
+			return fmt.Errorf("device %02X has more than one output file", dm.ID)
+		}
+
+		if dm.Input != "" {
+			prev.Input = dm.Input
+		}
+
+		if dm.Output != "" {
+			prev.Output = dm.Output
+			prev.Append = dm.Append
+		}
+	}
+
+	for _, id := range order {
+		dm := merged[id]
+
+		if dm.Missing {
+			m.AttachDevice(id, NewMissingDevice())
+		} else if dm.Clock {
+			m.AttachDevice(id, m.NewClockDevice())
+		} else if dm.Timer {
+			m.AttachDevice(id, m.NewTimerDevice())
+		} else if dm.Network != "" {
+			dev, err := dm.socket()
+			if err != nil {
+				return fmt.Errorf("failed to map device %02X: %w", id, err)
+			}
+
+			m.AttachDevice(id, dev)
+		} else if dm.Disk != "" {
+			dev, err := OpenDiskDevice(dm.Disk)
+			if err != nil {
+				return fmt.Errorf("failed to map device %02X: %w", id, err)
+			}
+
+			m.AttachDevice(id, dev)
+		} else {
+			m.AttachDevice(id, NewFileDevice(dm.Input, dm.Output, dm.Append))
+		}
+	}
+
+	return nil
+}
+
+// socket opens the socket device described by the mapping
+func (dm *DeviceMapping) socket() (*SocketDevice, error) {
+	if dm.Listen {
+		return ListenSocketDevice(dm.Network, dm.Address)
+	}
+
+	return DialSocketDevice(dm.Network, dm.Address)
+}
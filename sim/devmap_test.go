@@ -0,0 +1,162 @@
+package sim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseDeviceMapping checks the mapping grammar
+func TestParseDeviceMapping(t *testing.T) {
+	tests := []struct {
+		spec string
+		want DeviceMapping
+	}{
+		{"05=out.txt:w", DeviceMapping{ID: 0x05, Output: "out.txt"}},
+		{"06=in.txt:r", DeviceMapping{ID: 0x06, Input: "in.txt"}},
+		{"07=log:a", DeviceMapping{ID: 0x07, Output: "log", Append: true}},
+		{"08=data", DeviceMapping{ID: 0x08, Input: "data", Output: "data", Append: true}},
+		{"09=data:rw", DeviceMapping{ID: 0x09, Input: "data", Output: "data", Append: true}},
+		{" ff = dir/a:b:r ", DeviceMapping{ID: 0xFF, Input: "dir/a:b"}},
+		{"0A=a:b", DeviceMapping{ID: 0x0A, Input: "a:b", Output: "a:b", Append: true}},
+		{"0B=missing", DeviceMapping{ID: 0x0B, Missing: true}},
+		{"0C=clock", DeviceMapping{ID: 0x0C, Clock: true}},
+		{"0D=timer:r", DeviceMapping{ID: 0x0D, Timer: true}},
+		{"0E=disk:disk.img", DeviceMapping{ID: 0x0E, Disk: "disk.img"}},
+		{"0F=tcp:localhost:9000", DeviceMapping{ID: 0x0F, Network: "tcp", Address: "localhost:9000"}},
+		{"10=tcp-listen::9000", DeviceMapping{ID: 0x10, Network: "tcp", Address: ":9000", Listen: true}},
+		{"11=unix:/tmp/sock", DeviceMapping{ID: 0x11, Network: "unix", Address: "/tmp/sock"}},
+		{"12=unix-listen:/tmp/sock", DeviceMapping{ID: 0x12, Network: "unix", Address: "/tmp/sock", Listen: true}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDeviceMapping(tt.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tt.spec, err)
+		} else if got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"05", "XY=f", "100=f", "=f", "05=", "05=:w", "05=disk:", "05=tcp:", "05=unix-listen:"} {
+		if dm, err := ParseDeviceMapping(spec); err == nil {
+			t.Errorf("%q: got %+v, want an error", spec, dm)
+		}
+	}
+}
+
+// TestLoadDeviceConfig checks that comments are skipped and errors report the line
+func TestLoadDeviceConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devices.conf")
+	config := "# Devices\n\n05=in.txt:r\n  # Output\n06=out.txt:w\n"
+
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mappings, err := LoadDeviceConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mappings) != 2 || mappings[0].Input != "in.txt" || mappings[1].Output != "out.txt" {
+		t.Errorf("loaded %+v, want devices 05 and 06", mappings)
+	}
+
+	if err := os.WriteFile(path, []byte(config+"\n07\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadDeviceConfig(path); err == nil || !strings.HasPrefix(err.Error(), path+":7: ") {
+		t.Errorf("got %v, want an error on line 7", err)
+	}
+
+	if _, err := LoadDeviceConfig(filepath.Join(dir, "missing.conf")); err == nil {
+		t.Error("loaded a missing config")
+	}
+}
+
+// TestMapDevices checks merging mappings of the same device and rejecting conflicting ones
+func TestMapDevices(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+
+	if err := os.WriteFile(in, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var m Machine
+	m.New()
+	m.SetDeviceDir(dir)
+	defer m.Close()
+
+	err := m.MapDevices([]DeviceMapping{
+		{ID: 0x05, Input: in},
+		{ID: 0x05, Output: out},
+		{ID: 0x06, Missing: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val, err := m.ReadDevice(0x05); err != nil || val != 'x' {
+		t.Errorf("read %q, %v, want 'x'", val, err)
+	}
+
+	if err := m.WriteDevice(0x05, 'y'); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := os.ReadFile(out); string(got) != "y" {
+		t.Errorf("output %q, want \"y\"", got)
+	}
+
+	if state := m.DeviceState(0x06); state != DeviceBusy {
+		t.Errorf("missing device is %s, want %s", state, DeviceBusy)
+	}
+
+	conflicts := [][2]DeviceMapping{
+		{{ID: 0x07, Missing: true}, {ID: 0x07, Input: in}},
+		{{ID: 0x07, Network: "tcp", Address: ":0"}, {ID: 0x07, Output: out}},
+		{{ID: 0x07, Disk: "disk.img"}, {ID: 0x07, Input: in}},
+		{{ID: 0x07, Clock: true}, {ID: 0x07, Timer: true}},
+		{{ID: 0x07, Input: in}, {ID: 0x07, Input: in}},
+		{{ID: 0x07, Output: out}, {ID: 0x07, Output: out, Append: true}},
+	}
+
+	for _, pair := range conflicts {
+		if err := m.MapDevices(pair[:]); err == nil {
+			t.Errorf("mapped %+v and %+v to the same device", pair[0], pair[1])
+		}
+	}
+}
+
+// closingDevice records whether it was closed
+type closingDevice struct {
+	BufferDevice
+	closed bool
+}
+
+func (d *closingDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+// TestAttachDeviceCloses checks that replacing a device closes it
+func TestAttachDeviceCloses(t *testing.T) {
+	var m Machine
+	m.New()
+
+	old := &closingDevice{}
+	m.AttachDevice(0x05, old)
+
+	if err := m.MapDevices([]DeviceMapping{{ID: 0x05, Missing: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !old.closed {
+		t.Error("replaced device wasn't closed")
+	}
+}
@@ -0,0 +1,213 @@
+package sim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mnemonics maps opcodes to instruction names
+var mnemonics = map[byte]string{
+	ADD: "ADD", ADDF: "ADDF", ADDR: "ADDR", AND: "AND", CLEAR: "CLEAR", COMP: "COMP", COMPF: "COMPF",
+	COMPR: "COMPR", DIV: "DIV", DIVF: "DIVF", DIVR: "DIVR", FIX: "FIX", FLOAT: "FLOAT", HIO: "HIO",
+	J: "J", JEQ: "JEQ", JGT: "JGT", JLT: "JLT", JSUB: "JSUB", LDA: "LDA", LDB: "LDB", LDCH: "LDCH",
+	LDF: "LDF", LDL: "LDL", LDS: "LDS", LDT: "LDT", LDX: "LDX", LPS: "LPS", MUL: "MUL", MULF: "MULF",
+	MULR: "MULR", NORM: "NORM", OR: "OR", RD: "RD", RMO: "RMO", RSUB: "RSUB", SHIFTL: "SHIFTL",
+	SHIFTR: "SHIFTR", SIO: "SIO", SSK: "SSK", STA: "STA", STB: "STB", STCH: "STCH", STF: "STF",
+	STI: "STI", STL: "STL", STS: "STS", STSW: "STSW", STT: "STT", STX: "STX", SUB: "SUB",
+	SUBF: "SUBF", SUBR: "SUBR", SVC: "SVC", TD: "TD", TIO: "TIO", TIX: "TIX", TIXR: "TIXR", WD: "WD",
+}
+
+// registerNames maps register numbers to names
+var registerNames = map[int]string{
+	regA: "A", regX: "X", regL: "L", regB: "B", regS: "S", regT: "T", regF: "F", regPC: "PC", regSW: "SW",
+}
+
+// instructionFormat returns the format of the instruction with opcode (1 or 2), or 3 for
+// SIC, format 3 and format 4 instructions. It returns 0 for unknown opcodes.
+func instructionFormat(opcode byte) int {
+	switch opcode {
+	case FIX, FLOAT, HIO, NORM, SIO, TIO:
+		return 1
+	case ADDR, CLEAR, COMPR, DIVR, MULR, RMO, SHIFTL, SHIFTR, SUBR, SVC, TIXR:
+		return 2
+	}
+
+	if _, ok := mnemonics[opcode&0xFC]; ok {
+		return 3
+	}
+
+	return 0
+}
+
+// Disassemble decodes the instruction at addr, returning it in assembler syntax and its size in
+// bytes. Target addresses are shown as labels if the machine has a symbol for them.
+func (m *Machine) Disassemble(addr int) (string, int, error) {
+	opcode, err := m.Byte(addr)
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch instructionFormat(opcode) {
+	case 1:
+		return mnemonics[opcode], 1, nil
+	case 2:
+		operand, err := m.Byte(addr + 1)
+		if err != nil {
+			return "", 0, err
+		}
+
+		r1, r2 := int(operand>>4), int(operand&0x0F)
+
+		switch opcode {
+		case CLEAR, TIXR:
+			return fmt.Sprintf("%s %s", mnemonics[opcode], registerName(r1)), 2, nil
+		case SHIFTL, SHIFTR:
+			return fmt.Sprintf("%s %s,%d", mnemonics[opcode], registerName(r1), r2), 2, nil
+		case SVC:
+			return fmt.Sprintf("%s %d", mnemonics[opcode], r1), 2, nil
+		}
+
+		return fmt.Sprintf("%s %s,%s", mnemonics[opcode], registerName(r1), registerName(r2)), 2, nil
+	case 3:
+		return m.disassembleF3F4(addr, opcode)
+	}
+
+	return fmt.Sprintf("BYTE X'%02X'", opcode), 1, nil
+}
+
+// disassembleF3F4 decodes a SIC, format 3 or format 4 instruction
+func (m *Machine) disassembleF3F4(addr int, opcode byte) (string, int, error) {
+	var bytes [4]byte
+
+	for i := 0; i < 3; i++ {
+		val, err := m.Byte(addr + i)
+		if err != nil {
+			return "", 0, err
+		}
+
+		bytes[i] = val
+	}
+
+	name := mnemonics[opcode&0xFC]
+	ni := opcode & 0x03
+	indexed := bytes[1]&0x80 != 0
+
+	if opcode&0xFC == RSUB {
+		return name, 3, nil
+	}
+
+	var operand string
+	size := 3
+
+	if ni == 0 {
+		// SIC format with a 15-bit address
+		operand = m.addrName(int(bytes[1]&0x7F)<<8 | int(bytes[2]))
+	} else {
+		base, pc, extended := bytes[1]&0x40 != 0, bytes[1]&0x20 != 0, bytes[1]&0x10 != 0
+		disp := int(bytes[1]&0x0F)<<8 | int(bytes[2])
+
+		if extended {
+			val, err := m.Byte(addr + 3)
+			if err != nil {
+				return "", 0, err
+			}
+
+			name = "+" + name
+			disp = disp<<8 | int(val)
+			size = 4
+		}
+
+		switch {
+		case pc && !extended:
+			if disp >= 0x800 {
+				disp -= 0x1000
+			}
+
+			operand = m.addrName(addr + size + disp)
+		case base && !extended:
+			operand = fmt.Sprintf("0x%03X(B)", disp)
+		case ni == 1:
+			operand = fmt.Sprintf("%d", disp)
+		default:
+			operand = m.addrName(disp)
+		}
+
+		switch ni {
+		case 1:
+			operand = "#" + operand
+		case 2:
+			operand = "@" + operand
+		}
+	}
+
+	if indexed {
+		operand += ",X"
+	}
+
+	return name + " " + operand, size, nil
+}
+
+// registerName returns the name of register r
+func registerName(r int) string {
+	if name, ok := registerNames[r]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("R%d", r)
+}
+
+// addrName returns the label of addr, or addr in hex if it has none
+func (m *Machine) addrName(addr int) string {
+	if label := m.Label(addr); label != "" {
+		return label
+	}
+
+	return fmt.Sprintf("0x%06X", addr&0xFFFFFF)
+}
+
+// Label returns the label of addr, or an empty string if no symbol has that address.
+// If several symbols have the same address, the first one in alphabetical order is returned.
+func (m *Machine) Label(addr int) string {
+	return m.labels[addr]
+}
+
+// labelOf returns the label at or nearest before addr, or an empty string if there is none
+func labelOf(labels []symbol, addr int) string {
+	i := sort.Search(len(labels), func(i int) bool { return labels[i].addr > addr })
+	if i == 0 {
+		return ""
+	}
+
+	// Labels with the same address are sorted by name, use the first one like Label does
+	for i > 1 && labels[i-2].addr == labels[i-1].addr {
+		i--
+	}
+
+	return labels[i-1].name
+}
+
+// symbol is a label and its address
+type symbol struct {
+	name string
+	addr int
+}
+
+// sortedSymbols returns the machine's symbols sorted by address, then by name
+func (m *Machine) sortedSymbols() []symbol {
+	syms := make([]symbol, 0, len(m.symbols))
+
+	for name, addr := range m.symbols {
+		syms = append(syms, symbol{name: name, addr: addr})
+	}
+
+	sort.Slice(syms, func(i, j int) bool {
+		if syms[i].addr != syms[j].addr {
+			return syms[i].addr < syms[j].addr
+		}
+
+		return strings.Compare(syms[i].name, syms[j].name) < 0
+	})
+
+	return syms
+}
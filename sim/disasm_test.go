@@ -0,0 +1,61 @@
+package sim
+
+import "testing"
+
+// TestDisassembleF2 checks the operands of format 2 instructions
+func TestDisassembleF2(t *testing.T) {
+	tests := []struct {
+		code []byte
+		want string
+	}{
+		{[]byte{ADDR, 0x10}, "ADDR X,A"},
+		{[]byte{CLEAR, 0x50}, "CLEAR T"},
+		{[]byte{TIXR, 0x40}, "TIXR S"},
+		{[]byte{SHIFTL, 0x03}, "SHIFTL A,3"},
+		{[]byte{SHIFTR, 0x1F}, "SHIFTR X,15"},
+		{[]byte{SVC, 0x20}, "SVC 2"},
+	}
+
+	for _, tt := range tests {
+		var m Machine
+		m.New()
+
+		for i, b := range tt.code {
+			if err := m.SetByte(i, b); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, size, err := m.Disassemble(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != tt.want || size != 2 {
+			t.Errorf("% X: got %q (%d bytes), want %q (2 bytes)", tt.code, got, size, tt.want)
+		}
+	}
+}
+
+// TestLabel checks that the first label in alphabetical order is used and that setting the
+// symbols replaces the labels
+func TestLabel(t *testing.T) {
+	var m Machine
+	m.New()
+
+	if label := m.Label(0); label != "" {
+		t.Errorf("machine without symbols has label %q", label)
+	}
+
+	m.SetSymbols(map[string]int{"START": 0, "MAIN": 0, "LOOP": 6})
+
+	if m.Label(0) != "MAIN" || m.Label(6) != "LOOP" || m.Label(3) != "" {
+		t.Errorf("labels %q, %q and %q, want MAIN, LOOP and none", m.Label(0), m.Label(6), m.Label(3))
+	}
+
+	m.SetSymbols(map[string]int{"OTHER": 3})
+
+	if m.Label(0) != "" || m.Label(3) != "OTHER" {
+		t.Errorf("labels %q and %q after replacing the symbols, want none and OTHER", m.Label(0), m.Label(3))
+	}
+}
@@ -0,0 +1,333 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Disk images start with a header describing their geometry, followed by the sectors ordered by
+// track and then by sector:
+//
+//	0   magic "SICDISK" and a version byte
+//	8   number of tracks minus one (uint16, big endian)
+//	10  sectors per track minus one (uint16, big endian)
+//	12  sector size in bytes (uint16, big endian)
+//	14  reserved
+const (
+	diskMagic      = "SICDISK"
+	diskVersion    = 1
+	diskHeaderSize = 16
+)
+
+// Disk device commands, written with WD. Command arguments are written with the following WDs.
+const (
+	DiskSeek  = 0x01 // Seek to a track (2 bytes, big endian) and sector (1 byte)
+	DiskRead  = 0x02 // Read the current sector, its bytes are read with RD
+	DiskWrite = 0x03 // Write the current sector, its bytes are written with WD
+	DiskInfo  = 0x04 // Report the geometry, read with RD in the header's format (6 bytes)
+)
+
+// DiskGeometry describes the layout of a disk image
+type DiskGeometry struct {
+	Tracks     int // Number of tracks, at most 65536
+	Sectors    int // Number of sectors per track, at most 256
+	SectorSize int // Size of a sector in bytes, at most 65535
+}
+
+// DefaultDiskGeometry is the geometry of an 80 track disk with 18 sectors of 256 bytes per track
+var DefaultDiskGeometry = DiskGeometry{Tracks: 80, Sectors: 18, SectorSize: 256}
+
+// Size returns the number of data bytes on a disk
+func (g DiskGeometry) Size() int {
+	return g.Tracks * g.Sectors * g.SectorSize
+}
+
+func (g DiskGeometry) String() string {
+	return fmt.Sprintf("%d tracks, %d sectors per track, %d bytes per sector (%d bytes)",
+		g.Tracks, g.Sectors, g.SectorSize, g.Size())
+}
+
+// check returns an error if the geometry can't be stored in an image
+func (g DiskGeometry) check() error {
+	if g.Tracks < 1 || g.Tracks > 65536 {
+		return fmt.Errorf("invalid number of tracks: %d (must be between 1 and 65536)", g.Tracks)
+	}
+
+	if g.Sectors < 1 || g.Sectors > 256 {
+		return fmt.Errorf("invalid number of sectors per track: %d (must be between 1 and 256)", g.Sectors)
+	}
+
+	if g.SectorSize < 1 || g.SectorSize > 65535 {
+		return fmt.Errorf("invalid sector size: %d (must be between 1 and 65535)", g.SectorSize)
+	}
+
+	return nil
+}
+
+// header returns the geometry in the image header format, without the magic
+func (g DiskGeometry) header() []byte {
+	buf := make([]byte, 6)
+	binary.BigEndian.PutUint16(buf[0:], uint16(g.Tracks-1))
+	binary.BigEndian.PutUint16(buf[2:], uint16(g.Sectors-1))
+	binary.BigEndian.PutUint16(buf[4:], uint16(g.SectorSize))
+	return buf
+}
+
+// DiskImage is a disk image file
+type DiskImage struct {
+	Geometry DiskGeometry
+	file     *os.File
+}
+
+// CreateDiskImage creates an empty disk image, replacing any existing file
+func CreateDiskImage(path string, geo DiskGeometry) error {
+	if err := geo.check(); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create disk image: %w", err)
+	}
+
+	header := make([]byte, diskHeaderSize)
+	copy(header, diskMagic)
+	header[7] = diskVersion
+	copy(header[8:], geo.header())
+
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write disk image: %w", err)
+	}
+
+	if err := file.Truncate(int64(diskHeaderSize + geo.Size())); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write disk image: %w", err)
+	}
+
+	return file.Close()
+}
+
+// OpenDiskImage opens a disk image for reading and writing
+func OpenDiskImage(path string) (*DiskImage, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk image: %w", err)
+	}
+
+	header := make([]byte, diskHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read disk image header: %w", err)
+	}
+
+	if !bytes.Equal(header[:7], []byte(diskMagic)) || header[7] != diskVersion {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a disk image", path)
+	}
+
+	geo := DiskGeometry{
+		Tracks:     int(binary.BigEndian.Uint16(header[8:])) + 1,
+		Sectors:    int(binary.BigEndian.Uint16(header[10:])) + 1,
+		SectorSize: int(binary.BigEndian.Uint16(header[12:])),
+	}
+
+	if err := geo.check(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid disk image header: %w", err)
+	}
+
+	return &DiskImage{Geometry: geo, file: file}, nil
+}
+
+// offset returns the offset of a sector in the image file
+func (img *DiskImage) offset(track, sector int) (int64, error) {
+	if track < 0 || track >= img.Geometry.Tracks {
+		return 0, fmt.Errorf("invalid track: %d", track)
+	}
+
+	if sector < 0 || sector >= img.Geometry.Sectors {
+		return 0, fmt.Errorf("invalid sector: %d", sector)
+	}
+
+	return int64(diskHeaderSize + (track*img.Geometry.Sectors+sector)*img.Geometry.SectorSize), nil
+}
+
+// ReadSector returns the contents of a sector
+func (img *DiskImage) ReadSector(track, sector int) ([]byte, error) {
+	off, err := img.offset(track, sector)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, img.Geometry.SectorSize)
+	if _, err := img.file.ReadAt(buf, off); err != nil {
+		return nil, fmt.Errorf("failed to read sector %d/%d: %w", track, sector, err)
+	}
+
+	return buf, nil
+}
+
+// WriteSector writes data to a sector, padding it with zeros to the sector size
+func (img *DiskImage) WriteSector(track, sector int, data []byte) error {
+	off, err := img.offset(track, sector)
+	if err != nil {
+		return err
+	}
+
+	if len(data) > img.Geometry.SectorSize {
+		return fmt.Errorf("data doesn't fit into a sector: %d bytes", len(data))
+	}
+
+	buf := make([]byte, img.Geometry.SectorSize)
+	copy(buf, data)
+
+	if _, err := img.file.WriteAt(buf, off); err != nil {
+		return fmt.Errorf("failed to write sector %d/%d: %w", track, sector, err)
+	}
+
+	return nil
+}
+
+// Close closes the image file
+func (img *DiskImage) Close() error {
+	return img.file.Close()
+}
+
+// DiskDevice is a block storage device backed by a disk image.
+//
+// It is controlled with commands written with WD (see DiskSeek, DiskRead, DiskWrite and DiskInfo).
+// Sector data is transferred a byte at a time with RD and WD. After a sector is read or written,
+// the device moves to the next sector, continuing on the next track after the last sector.
+type DiskDevice struct {
+	img *DiskImage
+
+	track, sector int
+
+	cmd  byte   // Command receiving arguments or data, 0 if the device waits for a command
+	args []byte // Arguments or sector data received by cmd
+	out  []byte // Bytes waiting to be read with RD
+}
+
+// NewDiskDevice returns a disk device using img
+func NewDiskDevice(img *DiskImage) *DiskDevice {
+	return &DiskDevice{img: img}
+}
+
+// OpenDiskDevice opens a disk image and returns a disk device using it
+func OpenDiskDevice(path string) (*DiskDevice, error) {
+	img, err := OpenDiskImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDiskDevice(img), nil
+}
+
+// Position returns the current track and sector
+func (d *DiskDevice) Position() (track, sector int) {
+	return d.track, d.sector
+}
+
+func (d *DiskDevice) Test() bool {
+	return true
+}
+
+func (d *DiskDevice) Read() (byte, error) {
+	if len(d.out) == 0 {
+		return 0, fmt.Errorf("no data to read, issue a read or info command first")
+	}
+
+	val := d.out[0]
+	d.out = d.out[1:]
+
+	if len(d.out) == 0 && d.cmd == DiskRead {
+		d.cmd = 0
+		d.advance()
+	}
+
+	return val, nil
+}
+
+func (d *DiskDevice) Write(val byte) error {
+	switch d.cmd {
+	case 0:
+		return d.command(val)
+	case DiskSeek:
+		d.args = append(d.args, val)
+
+		if len(d.args) == 3 {
+			track, sector := int(d.args[0])<<8|int(d.args[1]), int(d.args[2])
+			d.cmd, d.args = 0, nil
+
+			if _, err := d.img.offset(track, sector); err != nil {
+				return fmt.Errorf("failed to seek: %w", err)
+			}
+
+			d.track, d.sector = track, sector
+		}
+	case DiskWrite:
+		d.args = append(d.args, val)
+
+		if len(d.args) == d.img.Geometry.SectorSize {
+			data := d.args
+			d.cmd, d.args = 0, nil
+
+			if err := d.img.WriteSector(d.track, d.sector, data); err != nil {
+				return err
+			}
+
+			d.advance()
+		}
+	default:
+		return fmt.Errorf("can't write while reading, read the rest of the sector first")
+	}
+
+	return nil
+}
+
+// command starts executing a command
+func (d *DiskDevice) command(cmd byte) error {
+	switch cmd {
+	case DiskSeek, DiskWrite:
+		d.cmd = cmd
+	case DiskRead:
+		data, err := d.img.ReadSector(d.track, d.sector)
+		if err != nil {
+			return err
+		}
+
+		d.cmd, d.out = cmd, data
+	case DiskInfo:
+		d.out = d.img.Geometry.header()
+	default:
+		return fmt.Errorf("unknown disk command: 0x%02X", cmd)
+	}
+
+	return nil
+}
+
+// advance moves to the next sector
+func (d *DiskDevice) advance() {
+	d.sector++
+
+	if d.sector == d.img.Geometry.Sectors {
+		d.sector = 0
+		d.track = (d.track + 1) % d.img.Geometry.Tracks
+	}
+}
+
+// Reset cancels the current command and seeks to the first sector
+func (d *DiskDevice) Reset() error {
+	d.track, d.sector = 0, 0
+	d.cmd, d.args, d.out = 0, nil, nil
+	return nil
+}
+
+// Close closes the disk image
+func (d *DiskDevice) Close() error {
+	return d.img.Close()
+}
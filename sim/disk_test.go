@@ -0,0 +1,199 @@
+package sim
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newDiskImage creates a disk image with 2 tracks of 3 sectors of 4 bytes in a temporary directory
+func newDiskImage(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "disk.img")
+
+	if err := CreateDiskImage(path, DiskGeometry{Tracks: 2, Sectors: 3, SectorSize: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// TestDiskImage checks reading and writing sectors and the sector and track bounds
+func TestDiskImage(t *testing.T) {
+	path := newDiskImage(t)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != diskHeaderSize+24 {
+		t.Errorf("image size %d, want %d", info.Size(), diskHeaderSize+24)
+	}
+
+	img, err := OpenDiskImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer img.Close()
+
+	if want := (DiskGeometry{Tracks: 2, Sectors: 3, SectorSize: 4}); img.Geometry != want {
+		t.Errorf("geometry %v, want %v", img.Geometry, want)
+	}
+
+	if err := img.WriteSector(1, 2, []byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := img.ReadSector(1, 2); err != nil || !bytes.Equal(data, []byte("ab\x00\x00")) {
+		t.Errorf("read %q, %v, want \"ab\" padded with zeros", data, err)
+	}
+
+	if data, _ := os.ReadFile(path); !bytes.Equal(data[diskHeaderSize+20:], []byte("ab\x00\x00")) {
+		t.Error("last sector isn't stored at the end of the image")
+	}
+
+	for _, pos := range [][2]int{{2, 0}, {0, 3}, {-1, 0}, {0, -1}} {
+		if _, err := img.ReadSector(pos[0], pos[1]); err == nil {
+			t.Errorf("reading sector %d/%d succeeded", pos[0], pos[1])
+		}
+
+		if err := img.WriteSector(pos[0], pos[1], nil); err == nil {
+			t.Errorf("writing sector %d/%d succeeded", pos[0], pos[1])
+		}
+	}
+
+	if err := img.WriteSector(0, 0, []byte("abcde")); err == nil {
+		t.Error("writing more than a sector succeeded")
+	}
+}
+
+// TestDiskImageHeader checks that invalid geometries and image headers are rejected
+func TestDiskImageHeader(t *testing.T) {
+	for _, geo := range []DiskGeometry{
+		{Tracks: 0, Sectors: 1, SectorSize: 1},
+		{Tracks: 65537, Sectors: 1, SectorSize: 1},
+		{Tracks: 1, Sectors: 257, SectorSize: 1},
+		{Tracks: 1, Sectors: 1, SectorSize: 65536},
+	} {
+		if err := CreateDiskImage(filepath.Join(t.TempDir(), "disk.img"), geo); err == nil {
+			t.Errorf("created an image with %v", geo)
+		}
+	}
+
+	valid, err := os.ReadFile(newDiskImage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		patch func(header []byte) []byte
+	}{
+		{"magic", func(h []byte) []byte { h[0] = 'X'; return h }},
+		{"version", func(h []byte) []byte { h[7] = diskVersion + 1; return h }},
+		{"sector size", func(h []byte) []byte { h[12], h[13] = 0, 0; return h }},
+		{"short header", func(h []byte) []byte { return h[:10] }},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "disk.img")
+		data := tt.patch(append([]byte{}, valid...))
+
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if img, err := OpenDiskImage(path); err == nil {
+			img.Close()
+			t.Errorf("%s: opened an invalid image", tt.name)
+		}
+	}
+}
+
+// TestDiskDevice checks the seek, read, write and info commands
+func TestDiskDevice(t *testing.T) {
+	d, err := OpenDiskDevice(newDiskImage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	write := func(vals ...byte) error {
+		for _, val := range vals {
+			if err := d.Write(val); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	read := func(n int) []byte {
+		t.Helper()
+		buf := make([]byte, n)
+
+		for i := range buf {
+			val, err := d.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf[i] = val
+		}
+
+		return buf
+	}
+
+	// Writing the last sector continues on the first track
+	if err := write(DiskSeek, 0x00, 0x01, 0x02, DiskWrite, 'w', 'x', 'y', 'z'); err != nil {
+		t.Fatal(err)
+	}
+
+	if track, sector := d.Position(); track != 0 || sector != 0 {
+		t.Errorf("after writing sector 1/2: at %d/%d, want 0/0", track, sector)
+	}
+
+	if err := write(DiskSeek, 0x00, 0x01, 0x02, DiskRead); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Write(DiskRead); err == nil {
+		t.Error("writing while reading a sector succeeded")
+	}
+
+	if got := read(4); string(got) != "wxyz" {
+		t.Errorf("read %q, want \"wxyz\"", got)
+	}
+
+	if err := write(DiskInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := read(6); !bytes.Equal(got, []byte{0, 1, 0, 2, 0, 4}) {
+		t.Errorf("info % X, want 00 01 00 02 00 04", got)
+	}
+
+	if err := write(DiskSeek, 0x00, 0x02, 0x00); err == nil {
+		t.Error("seeking past the last track succeeded")
+	}
+
+	if err := write(DiskSeek, 0x00, 0x00, 0x03); err == nil {
+		t.Error("seeking past the last sector succeeded")
+	}
+
+	if track, sector := d.Position(); track != 0 || sector != 0 {
+		t.Errorf("failed seeks moved to %d/%d", track, sector)
+	}
+
+	if _, err := d.Read(); err == nil {
+		t.Error("reading without a command succeeded")
+	}
+
+	if err := write(0x7F); err == nil {
+		t.Error("unknown command succeeded")
+	}
+}
@@ -5,28 +5,170 @@ import (
 	"fmt"
 )
 
-// Keeps track of jump addresses to detect loops - halt execution
-var JMPADDR int
-var LASTINST byte
-
 // fetch returns a byte from m[PC] and increments PC
 func (m *Machine) fetch() byte {
 	addr := m.PC()
 	m.SetPC(addr + 1)
-	val, _ := m.Byte(addr)
+	m.fetched++
+	return m.readByte(addr)
+}
+
+// readByte returns the byte at addr, raising an address fault if addr is invalid
+func (m *Machine) readByte(addr int) byte {
+	val, err := m.Byte(addr)
+	if err != nil {
+		m.raiseAddress(addr)
+	}
+
+	return val
+}
+
+// readWord returns the word at addr, raising an address fault if addr is invalid
+func (m *Machine) readWord(addr int) int {
+	val, err := m.Word(addr)
+	if err != nil {
+		m.raiseAddress(addr)
+	}
+
+	return val
+}
+
+// writeByte sets the byte at addr, raising an address fault if addr is invalid. Nothing is
+// written if the instruction already faulted, e.g. while reading an indirect address.
+func (m *Machine) writeByte(addr int, val byte) {
+	if m.fault != nil {
+		return
+	}
+
+	if err := m.SetByte(addr, val); err != nil {
+		m.raiseAddress(addr)
+	}
+}
+
+// writeWord sets the word at addr like writeByte
+func (m *Machine) writeWord(addr, val int) {
+	if m.fault != nil {
+		return
+	}
+
+	if err := m.SetWord(addr, val); err != nil {
+		m.raiseAddress(addr)
+	}
+}
+
+// arith sets register r to op(a, b), raising an overflow fault instead if the operation
+// overflowed and overflow trapping is enabled
+func (m *Machine) arith(r, a, b int, op func(Word, Word) (Word, bool)) {
+	val, overflow := op(NewWord(a), NewWord(b))
+
+	if overflow && m.overflowTrap {
+		m.raise(FaultOverflow, nil)
+		return
+	}
+
+	m.setReg(r, val.Int())
+}
+
+// divide sets register r to a / b, raising a fault if b is zero
+func (m *Machine) divide(r, a, b int) {
+	if b == 0 {
+		m.raise(FaultDivideByZero, nil)
+		return
+	}
+
+	m.arith(r, a, b, Word.Div)
+}
+
+// reg returns the value of register r, raising an illegal instruction fault if r is invalid
+func (m *Machine) reg(r int) int {
+	val, err := m.Reg(r)
+	if err != nil {
+		m.raise(FaultIllegalInstruction, err)
+	}
+
 	return val
 }
 
-// Execute executes each fetched instruction
+// setReg sets the value of register r, raising an illegal instruction fault if r is invalid
+func (m *Machine) setReg(r, val int) {
+	if !isRegister(r) {
+		m.raise(FaultIllegalInstruction, fmt.Errorf("not a valid register: %d", r))
+		return
+	}
+
+	m.SetReg(r, val)
+}
+
+// Execute executes the next instruction, unless the halt policy stops the machine first.
+// If the instruction faults, its registers, cycle count and subroutine call stack are restored
+// to what they were before the instruction and a program interrupt is taken if enabled, otherwise
+// the fault is returned. Memory and devices aren't restored, but stores and device accesses are
+// skipped once an instruction faulted, and stores check their address before writing.
 func (m *Machine) Execute() error {
+	m.instAddr = m.PC()
+
+	if m.haltBefore() {
+		return nil
+	}
+
+	regs, cycles, stack := m.regs, m.cycles, m.stack
+	m.fault = nil
+	m.fetched = 0
+	m.timerSet = false
+
+	if err := m.execute(); err != nil {
+		m.raise(FaultIllegalInstruction, err)
+	}
+
+	if fault := m.fault; fault != nil {
+		m.fault = nil
+		m.regs = regs
+		m.cycles = cycles
+		m.stack = stack
+
+		// The saved PC points past the bytes of the instruction fetched before the fault
+		m.SetPC(m.instAddr + m.fetched)
+
+		if !m.interrupt(InterruptProgram, fault.Kind.code()) {
+			m.SetPC(m.instAddr)
+			return fault
+		}
+	}
+
+	m.instructions++
+
+	if m.profiler != nil || m.coverage != nil {
+		opcode, _ := m.Byte(m.instAddr)
+
+		if m.profiler != nil {
+			m.profiler.record(m.instAddr, opcode, m.cycles-cycles, m.PC())
+		}
+
+		if m.coverage != nil {
+			m.coverage.record(m.instAddr, opcode, m.CC())
+		}
+	}
+
+	m.tick(m.cycles - cycles)
+	m.runChannels(m.cycles - cycles)
+	m.haltAfter()
+	return nil
+}
+
+// execute fetches and executes a single instruction
+func (m *Machine) execute() error {
 	var success bool
 	var err error
 
 	opcode := m.fetch()
 
+	if err := m.checkProfile(opcode); err != nil {
+		return err
+	}
+
 	if success, err = m.execF1(opcode); err == nil {
 		if success {
-			LASTINST = opcode
+			m.charge(1, opcode, 0, false)
 			return nil
 		}
 	} else {
@@ -37,7 +179,7 @@ func (m *Machine) Execute() error {
 
 	if success, err = m.execF2(opcode, operands); err == nil {
 		if success {
-			LASTINST = opcode
+			m.charge(2, opcode, 0, false)
 			return nil
 		}
 	} else {
@@ -49,7 +191,6 @@ func (m *Machine) Execute() error {
 
 	if success, err = m.execSICF3F4(opcode, operands, ni); err == nil {
 		if success {
-			LASTINST = opcode
 			return nil
 		}
 	} else {
@@ -62,7 +203,7 @@ func (m *Machine) Execute() error {
 // calcStoreOperand returns the proper operand for store instructions
 func (m *Machine) calcStoreOperand(addr int, indirect bool) int {
 	if indirect {
-		addr, _ = m.Word(addr)
+		addr = m.readWord(addr) & 0xFFFFFF
 	}
 
 	return addr
@@ -74,10 +215,10 @@ func (m *Machine) calcOperand(operand int, indirect, immediate bool) int {
 		return operand
 	}
 
-	operand, _ = m.Word(operand)
+	operand = m.readWord(operand)
 
 	if indirect {
-		operand, _ = m.Word(operand)
+		operand = m.readWord(operand & 0xFFFFFF)
 	}
 
 	return operand
@@ -90,37 +231,59 @@ func (m *Machine) calcByteOperand(operand int, indirect, immediate bool) byte {
 	}
 
 	if indirect {
-		val, _ := m.Word(operand)
-		op, _ := m.Byte(val)
-		return op
+		return m.readByte(m.readWord(operand) & 0xFFFFFF)
 	}
 
-	op, _ := m.Byte(operand)
-	return op
+	return m.readByte(operand)
 }
 
 // execF1 tries to execute opcode as format 1
 func (m *Machine) execF1(opcode byte) (bool, error) {
+	switch opcode {
+	case HIO, SIO, TIO:
+		if !m.Supervisor() {
+			m.raise(FaultPrivileged, nil)
+			return true, nil
+		}
+	}
+
 	switch opcode {
 	case FIX:
 		return false, fmt.Errorf("instruction not implemented: %s", "FIX")
 	case FLOAT:
 		return false, fmt.Errorf("instruction not implemented: %s", "FLOAT")
 	case HIO:
-		return false, fmt.Errorf("instruction not implemented: %s", "HIO")
+		if err := m.HaltIO(m.A()); err != nil {
+			m.raise(FaultIllegalInstruction, err)
+		}
 	case NORM:
 		return false, fmt.Errorf("instruction not implemented: %s", "NORM")
 	case SIO:
-		return false, fmt.Errorf("instruction not implemented: %s", "SIO")
+		if err := checkChannel(m.A()); err != nil {
+			m.raise(FaultIllegalInstruction, err)
+			break
+		}
+
+		// A busy channel isn't restarted, which is reported with CC set to EQ
+		if err := m.StartIO(m.A(), m.S()&0xFFFFFF); err != nil {
+			m.setCC(EQ)
+		} else {
+			m.setCC(LT)
+		}
 	case TIO:
-		return false, fmt.Errorf("instruction not implemented: %s", "TIO")
+		status, err := m.TestIO(m.A())
+		if err != nil {
+			m.raise(FaultIllegalInstruction, err)
+			break
+		}
+
+		m.setCC(status.cc())
 	default:
 		// Not a format 1 instruction
 		return false, nil
 	}
 
-	// Currently unreachable
-	// return true, nil
+	return true, nil
 }
 
 // execF2 tries to execute opcode as format 2
@@ -130,57 +293,35 @@ func (m *Machine) execF2(opcode, operand byte) (bool, error) {
 
 	switch opcode {
 	case ADDR:
-		r1, _ := m.Reg(op1)
-		r2, _ := m.Reg(op2)
-		m.SetReg(op2, r2+r1)
+		m.arith(op2, m.reg(op2), m.reg(op1), Word.Add)
 	case CLEAR:
-		m.SetReg(op1, 0)
+		m.setReg(op1, 0)
 	case COMPR:
-		r1, _ := m.Reg(op1)
-		r2, _ := m.Reg(op2)
-
-		if r1 > r2 {
-			m.SetSW(GT)
-		} else if r1 == r2 {
-			m.SetSW(EQ)
-		} else {
-			m.SetSW(LT)
-		}
+		m.compare(m.reg(op1), m.reg(op2))
 	case DIVR:
-		r1, _ := m.Reg(op1)
-		r2, _ := m.Reg(op2)
-		m.SetReg(op2, r2/r1)
+		m.divide(op2, m.reg(op2), m.reg(op1))
 	case MULR:
-		r1, _ := m.Reg(op1)
-		r2, _ := m.Reg(op2)
-		m.SetReg(op2, r2*r1)
+		m.arith(op2, m.reg(op2), m.reg(op1), Word.Mul)
 	case RMO:
-		r1, _ := m.Reg(op1)
-		m.SetReg(op2, r1)
+		m.setReg(op2, m.reg(op1))
 	case SHIFTL:
-		r1, _ := m.Reg(op1)
-		m.SetReg(op1, r1<<op2)
+		m.setReg(op1, NewWord(m.reg(op1)).ShiftLeft(op2).Int())
 	case SHIFTR:
-		r1, _ := m.Reg(op1)
-		m.SetReg(op1, r1>>op2)
+		m.setReg(op1, NewWord(m.reg(op1)).ShiftRight(op2).Int())
 	case SUBR:
-		r1, _ := m.Reg(op1)
-		r2, _ := m.Reg(op2)
-		m.SetReg(op2, r2-r1)
+		m.arith(op2, m.reg(op2), m.reg(op1), Word.Sub)
 	case SVC:
-		return false, fmt.Errorf("instruction not implemented: %s", "SVC")
-	case TIXR:
-		r1, _ := m.Reg(op1)
-		m.SetX(m.X() + 1)
-		rX := m.X()
-
-		if rX > r1 {
-			m.SetSW(GT)
-		} else if rX == r1 {
-			m.SetSW(EQ)
-		} else {
-			m.SetSW(LT)
+		if m.haltPolicy.SVC {
+			m.haltWith(HaltSVC)
+			return true, nil
+		}
+
+		if !m.interrupt(InterruptSVC, op1) {
+			m.raise(FaultIllegalInstruction, fmt.Errorf("SVC interrupts are disabled"))
 		}
+	case TIXR:
+		m.arith(regX, m.X(), 1, Word.Add)
+		m.compare(m.X(), m.reg(op1))
 	default:
 		// Not a format 2 instruction
 		return false, nil
@@ -211,7 +352,8 @@ func (m *Machine) execSICF3F4(opcode, operands, ni byte) (bool, error) {
 		} else if bp == 0x20 {
 			pcrelative = true
 		} else if bp == 0x60 {
-			return false, fmt.Errorf("wrong addressing format")
+			m.raise(FaultIllegalInstruction, fmt.Errorf("wrong addressing format"))
+			return true, nil
 		}
 
 		if ni == 0x02 {
@@ -247,70 +389,61 @@ func (m *Machine) execSICF3F4(opcode, operands, ni byte) (bool, error) {
 		operand += m.X()
 	}
 
-	if debug {
-		fmt.Printf("Instruction: 0x%02X (0x%04X)\n", opcode, operand)
-		fmt.Println("Addressing:")
-		fmt.Printf("  SIC: %v\n", sic)
-		fmt.Printf("  indirect: %v\n", indirect)
-		fmt.Printf("  direct: %v\n", direct)
-		fmt.Printf("  extended: %v\n", extended)
-		fmt.Printf("  indexed: %v\n", indexed)
-		fmt.Printf("  immediate: %v\n", immediate)
-		fmt.Printf("  base relative: %v\n", baserelative)
-		fmt.Printf("  pc relative: %v\n", pcrelative)
+	if m.debug {
+		m.logger.Printf("Instruction: 0x%02X (0x%04X)\n", opcode, operand)
+		m.logger.Println("Addressing:")
+		m.logger.Printf("  SIC: %v\n", sic)
+		m.logger.Printf("  indirect: %v\n", indirect)
+		m.logger.Printf("  direct: %v\n", direct)
+		m.logger.Printf("  extended: %v\n", extended)
+		m.logger.Printf("  indexed: %v\n", indexed)
+		m.logger.Printf("  immediate: %v\n", immediate)
+		m.logger.Printf("  base relative: %v\n", baserelative)
+		m.logger.Printf("  pc relative: %v\n", pcrelative)
+	}
+
+	switch opcode {
+	case LPS, SSK, STI:
+		if !m.Supervisor() {
+			m.raise(FaultPrivileged, nil)
+			return true, nil
+		}
 	}
 
 	switch opcode {
 	case ADD:
-		m.SetA(m.A() + m.calcOperand(operand, indirect, immediate))
+		m.arith(regA, m.A(), m.calcOperand(operand, indirect, immediate), Word.Add)
 	case ADDF:
 		return false, fmt.Errorf("instruction not implemented: %s", "ADDF")
 	case AND:
 		m.SetA(m.A() & m.calcOperand(operand, indirect, immediate))
 	case COMP:
-		rA := m.A()
-		val := m.calcOperand(operand, indirect, immediate)
-
-		if rA > val {
-			m.SetSW(GT)
-		} else if rA == val {
-			m.SetSW(EQ)
-		} else {
-			m.SetSW(LT)
-		}
+		m.compare(m.A(), m.calcOperand(operand, indirect, immediate))
 	case COMPF:
 		return false, fmt.Errorf("instruction not implemented: %s", "COMPF")
 	case DIV:
-		m.SetA(m.A() / m.calcOperand(operand, indirect, immediate))
+		m.divide(regA, m.A(), m.calcOperand(operand, indirect, immediate))
 	case DIVF:
 		return false, fmt.Errorf("instruction not implemented: %s", "DIVF")
 	case J:
 		addr := m.calcStoreOperand(operand, indirect)
 
-		if debug {
-			fmt.Printf("Jump addr: 0x%02X\n", addr)
-		}
-
-		// Halt processor
-		if addr == JMPADDR && LASTINST == J {
-			m.halted = true
-			return true, nil
-		} else {
-			JMPADDR = addr
+		if m.debug {
+			m.logger.Printf("Jump addr: 0x%02X\n", addr)
 		}
 
-		m.SetPC(addr)
+		m.jump(addr)
 	case JEQ:
-		if m.SW() == EQ {
-			m.SetPC(m.calcStoreOperand(operand, indirect))
+		if m.CC() == EQ {
+			m.jump(m.calcStoreOperand(operand, indirect))
 		}
 	case JGT:
-		if m.SW() == GT {
-			m.SetPC(m.calcStoreOperand(operand, indirect))
+		if m.CC() == GT {
+			m.jump(m.calcStoreOperand(operand, indirect))
 		}
 	case JLT:
-		if m.SW() == LT {
-			m.SetPC(m.calcStoreOperand(operand, indirect))
+		if m.CC() == LT {
+			m.jump(m.calcStoreOperand(operand, indirect))
 		}
 	case JSUB:
 		m.SetL(m.PC())
@@ -333,74 +466,108 @@ func (m *Machine) execSICF3F4(opcode, operands, ni byte) (bool, error) {
 	case LDX:
 		m.SetX(m.calcOperand(operand, indirect, immediate))
 	case LPS:
-		return false, fmt.Errorf("instruction not implemented: %s", "LPS")
+		m.loadStatus(m.calcStoreOperand(operand, indirect))
 	case MUL:
-		m.SetA(m.A() * m.calcOperand(operand, indirect, immediate))
+		m.arith(regA, m.A(), m.calcOperand(operand, indirect, immediate), Word.Mul)
 	case MULF:
 		return false, fmt.Errorf("instruction not implemented: %s", "MULF")
 	case OR:
 		m.SetA(m.A() | m.calcOperand(operand, indirect, immediate))
 	case RD:
-		char, err := m.ReadDevice(m.calcByteOperand(operand, indirect, immediate))
+		id := m.calcByteOperand(operand, indirect, immediate)
+		if m.fault != nil {
+			break
+		}
+
+		char, err := m.ReadDevice(id)
 		if err != nil {
-			fmt.Println(err)
-			return false, err
+			m.raise(FaultDevice, err)
+			break
 		}
 
 		m.SetALow(char)
 	case RSUB:
 		m.SetPC(m.L())
-		m.SetL(m.pop())
+
+		if len(m.stack) > 0 {
+			m.SetL(m.pop())
+		}
 	case SSK:
 		return false, fmt.Errorf("instruction not implemented: %s", "SSK")
 	case STA:
-		m.SetWord(m.calcStoreOperand(operand, indirect), m.A())
+		m.writeWord(m.calcStoreOperand(operand, indirect), m.A())
 	case STB:
-		m.SetWord(m.calcStoreOperand(operand, indirect), m.B())
+		m.writeWord(m.calcStoreOperand(operand, indirect), m.B())
 	case STCH:
-		m.SetByte(m.calcStoreOperand(operand, indirect), m.ALow())
+		m.writeByte(m.calcStoreOperand(operand, indirect), m.ALow())
 	case STF:
-		m.SetWord(m.calcStoreOperand(operand, indirect), m.F())
+		m.writeWord(m.calcStoreOperand(operand, indirect), m.F())
 	case STI:
-		return false, fmt.Errorf("instruction not implemented: %s", "STI")
+		if val := m.readWord(m.calcStoreOperand(operand, indirect)); m.fault == nil {
+			m.SetTimer(val & 0xFFFFFF)
+		}
 	case STL:
-		m.SetWord(m.calcStoreOperand(operand, indirect), m.L())
+		m.writeWord(m.calcStoreOperand(operand, indirect), m.L())
 	case STS:
-		m.SetWord(m.calcStoreOperand(operand, indirect), m.S())
+		m.writeWord(m.calcStoreOperand(operand, indirect), m.S())
 	case STSW:
-		m.SetWord(m.calcStoreOperand(operand, indirect), m.SW())
+		m.writeWord(m.calcStoreOperand(operand, indirect), m.SW())
 	case STT:
-		m.SetWord(m.calcStoreOperand(operand, indirect), m.T())
+		m.writeWord(m.calcStoreOperand(operand, indirect), m.T())
 	case STX:
-		m.SetWord(m.calcStoreOperand(operand, indirect), m.X())
+		m.writeWord(m.calcStoreOperand(operand, indirect), m.X())
 	case SUB:
-		m.SetA(m.A() - m.calcOperand(operand, indirect, immediate))
+		m.arith(regA, m.A(), m.calcOperand(operand, indirect, immediate), Word.Sub)
 	case SUBF:
 		return false, fmt.Errorf("instruction not implemented: %s", "SUBF")
 	case TD:
-		m.TestDevice(m.calcByteOperand(operand, indirect, immediate))
-	case TIX:
-		m.SetX(m.X() + 1)
-		rX := m.X()
-		val := m.calcOperand(operand, indirect, immediate)
-
-		if rX > val {
-			m.SetSW(GT)
-		} else if rX == val {
-			m.SetSW(EQ)
-		} else {
-			m.SetSW(LT)
+		if id := m.calcByteOperand(operand, indirect, immediate); m.fault == nil {
+			m.setCC(m.DeviceState(id).cc())
 		}
+	case TIX:
+		m.arith(regX, m.X(), 1, Word.Add)
+		m.compare(m.X(), m.calcOperand(operand, indirect, immediate))
 	case WD:
-		err := m.WriteDevice(m.calcByteOperand(operand, indirect, immediate), m.ALow())
+		id := m.calcByteOperand(operand, indirect, immediate)
+		if m.fault != nil {
+			break
+		}
+
+		err := m.WriteDevice(id, m.ALow())
 		if err != nil {
-			fmt.Println(err)
-			return false, err
+			m.raise(FaultDevice, err)
+			break
+		}
+
+		if m.haltPolicy.DeviceWrites[id] {
+			m.haltWith(HaltDeviceWrite)
 		}
 	default:
 		// Not a format 3, 4, SIC instruction
 		return false, nil
 	}
 
+	format := 3
+	if sic {
+		format = 0
+	} else if extended {
+		format = 4
+	}
+
+	// Jumps only use the target address, other instructions read or write their operand
+	memory := 0
+	switch opcode {
+	case J, JEQ, JGT, JLT, JSUB, RSUB:
+	default:
+		if !immediate {
+			memory++
+		}
+	}
+
+	if indirect {
+		memory++
+	}
+
+	m.charge(format, opcode, memory, indirect)
 	return true, nil
 }
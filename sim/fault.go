@@ -0,0 +1,144 @@
+package sim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FaultKind classifies program faults
+type FaultKind int
+
+const (
+	FaultIllegalInstruction FaultKind = iota // Unknown or unimplemented instruction, or invalid operands
+	FaultPrivileged                          // Privileged instruction executed in user mode
+	FaultAddress                             // Memory access outside of the machine's memory
+	FaultDivideByZero                        // DIV or DIVR with a zero divisor
+	FaultDevice                              // Device could not be read or written
+	FaultOverflow                            // Arithmetic overflow, only raised if overflow trapping is enabled
+)
+
+func (k FaultKind) String() string {
+	switch k {
+	case FaultIllegalInstruction:
+		return "illegal instruction"
+	case FaultPrivileged:
+		return "privileged instruction in user mode"
+	case FaultAddress:
+		return "address out of range"
+	case FaultDivideByZero:
+		return "division by zero"
+	case FaultDevice:
+		return "device error"
+	case FaultOverflow:
+		return "arithmetic overflow"
+	}
+
+	return fmt.Sprintf("unknown fault (%d)", int(k))
+}
+
+// code returns the interruption code stored in SW when the fault raises a program interrupt
+func (k FaultKind) code() int {
+	switch k {
+	case FaultIllegalInstruction:
+		return 0x00
+	case FaultPrivileged:
+		return 0x01
+	case FaultAddress:
+		return 0x02
+	case FaultDivideByZero:
+		return 0x05
+	case FaultDevice:
+		return 0x06
+	case FaultOverflow:
+		return 0x04
+	}
+
+	return 0x3F
+}
+
+// Fault describes an instruction that failed to execute
+type Fault struct {
+	Kind  FaultKind
+	PC    int    // Address of the faulting instruction
+	Bytes []byte // Raw bytes of the faulting instruction, as far as they were fetched
+	Addr  int    // Offending address, if Kind is FaultAddress
+	Err   error  // Underlying cause, if any
+}
+
+func (f *Fault) Error() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s at 0x%06X (", f.Kind, f.PC)
+
+	for i, b := range f.Bytes {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+
+		fmt.Fprintf(&sb, "%02X", b)
+	}
+
+	sb.WriteString(")")
+
+	if f.Kind == FaultAddress {
+		fmt.Fprintf(&sb, ": address 0x%X", f.Addr)
+	}
+
+	if f.Err != nil {
+		fmt.Fprintf(&sb, ": %v", f.Err)
+	}
+
+	return sb.String()
+}
+
+func (f *Fault) Unwrap() error {
+	return f.Err
+}
+
+// Opcode returns the first byte of the faulting instruction
+func (f *Fault) Opcode() byte {
+	if len(f.Bytes) == 0 {
+		return 0
+	}
+
+	return f.Bytes[0]
+}
+
+// newFault returns a fault of the current instruction
+func (m *Machine) newFault(kind FaultKind, err error) *Fault {
+	fault := &Fault{Kind: kind, PC: m.instAddr, Err: err}
+
+	// Bytes fetched so far, at least the opcode and at most a format 4 instruction
+	n := m.PC() - m.instAddr
+	if n < 1 {
+		n = 1
+	} else if n > 4 {
+		n = 4
+	}
+
+	for i := 0; i < n; i++ {
+		val, err := m.Byte(m.instAddr + i)
+		if err != nil {
+			break
+		}
+
+		fault.Bytes = append(fault.Bytes, val)
+	}
+
+	return fault
+}
+
+// raise records a fault of the current instruction, only the first fault is kept
+func (m *Machine) raise(kind FaultKind, err error) {
+	if m.fault == nil {
+		m.fault = m.newFault(kind, err)
+	}
+}
+
+// raiseAddress records an address fault of the current instruction
+func (m *Machine) raiseAddress(addr int) {
+	if m.fault == nil {
+		m.fault = m.newFault(FaultAddress, nil)
+		m.fault.Addr = addr
+	}
+}
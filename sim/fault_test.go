@@ -0,0 +1,87 @@
+package sim
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestFaultRollback checks that faulting instructions leave registers, the call stack and memory unchanged
+func TestFaultRollback(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+	}{
+		// Format 4 with indirect addressing, through a pointer straddling the end of memory
+		{"JSUB", []byte{JSUB | 0x02, 0x1F, 0xFF, 0xFF}},
+		{"STA", []byte{STA | 0x02, 0x1F, 0xFF, 0xFF}},
+		{"STCH", []byte{STCH | 0x02, 0x1F, 0xFF, 0xFF}},
+	}
+
+	for _, tt := range tests {
+		var m Machine
+		m.New()
+		m.SetA(0x414243)
+		m.SetL(0x10)
+		m.SetPC(0x100)
+
+		for i, b := range tt.code {
+			m.SetByte(0x100+i, b)
+		}
+
+		err := m.Execute()
+
+		var fault *Fault
+		if !errors.As(err, &fault) || fault.Kind != FaultAddress {
+			t.Errorf("%s: got %v, want an address fault", tt.name, err)
+			continue
+		}
+
+		if m.PC() != 0x100 || m.L() != 0x10 || len(m.stack) != 0 {
+			t.Errorf("%s: PC %06X, L %06X and %d return addresses after the fault", tt.name, m.PC(), m.L(), len(m.stack))
+		}
+
+		if word, _ := m.Word(0); word != 0 {
+			t.Errorf("%s: faulting instruction stored %06X at address 0", tt.name, word)
+		}
+	}
+}
+
+// TestFaultDeviceAccess checks that device instructions whose operand faults don't access the device
+func TestFaultDeviceAccess(t *testing.T) {
+	for _, opcode := range []byte{RD, TD, WD} {
+		var m Machine
+		m.New()
+		m.SetProfile(ProfileXE.WithMemSize(0x1000))
+		m.SetDeviceDir(t.TempDir())
+
+		dev := NewBufferDevice([]byte("x"))
+		m.AttachDevice(0, dev)
+
+		if err := m.SetDeviceLatency(0, Latency{Instructions: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Format 4 with an operand past the end of memory, which reads as device 0
+		for i, b := range []byte{opcode | 0x03, 0x10, 0x80, 0x00} {
+			m.SetByte(i, b)
+		}
+
+		var fault *Fault
+		if err := m.Execute(); !errors.As(err, &fault) || fault.Kind != FaultAddress {
+			t.Errorf("%02X: got %v, want an address fault", opcode, err)
+			continue
+		}
+
+		if m.deviceBusy(0) {
+			t.Errorf("%02X: faulting instruction made the device busy", opcode)
+		}
+
+		if len(dev.Output()) > 0 {
+			t.Errorf("%02X: faulting instruction wrote %q", opcode, dev.Output())
+		}
+
+		if val, err := dev.Read(); val != 'x' || err != nil {
+			t.Errorf("%02X: faulting instruction consumed the input", opcode)
+		}
+	}
+}
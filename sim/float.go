@@ -0,0 +1,38 @@
+package sim
+
+import (
+	"math"
+)
+
+// FloatFromBytes returns the value of the 48-bit SIC/XE float with the big-endian bytes b: a sign
+// bit, an 11-bit exponent with a bias of 1024 and a 36-bit fraction f, so the value is f * 2^(exp-1024)
+// with 0 <= f < 1
+func FloatFromBytes(b [6]byte) float64 {
+	var bits uint64
+	for _, val := range b {
+		bits = bits<<8 | uint64(val)
+	}
+
+	sign := bits >> 47
+	exp := int(bits>>36) & 0x7FF
+	frac := bits & (1<<36 - 1)
+
+	val := math.Ldexp(float64(frac), exp-1024-36)
+	if sign != 0 {
+		val = -val
+	}
+
+	return val
+}
+
+// Float returns the 48-bit float at m[addr..addr+5]
+func (m *Machine) Float(addr int) (float64, error) {
+	data, err := m.ReadMem(addr, 6)
+	if err != nil {
+		return 0, err
+	}
+
+	var b [6]byte
+	copy(b[:], data)
+	return FloatFromBytes(b), nil
+}
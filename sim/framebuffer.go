@@ -0,0 +1,174 @@
+package sim
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"sync"
+	"time"
+)
+
+// Default address and size of the memory-mapped framebuffer, as used by SicTools
+const (
+	FramebufferAddr   = 0xA000
+	FramebufferWidth  = 64
+	FramebufferHeight = 64
+)
+
+// DefaultPalette maps pixel bytes in the form xxRRGGBB to colors, where each of the red, green
+// and blue fields selects one of 4 levels. The highest 2 bits are ignored.
+var DefaultPalette = defaultPalette()
+
+func defaultPalette() color.Palette {
+	levels := [4]uint8{0x00, 0x55, 0xAA, 0xFF}
+	palette := make(color.Palette, 256)
+
+	for i := range palette {
+		palette[i] = color.RGBA{R: levels[(i>>4)&3], G: levels[(i>>2)&3], B: levels[i&3], A: 0xFF}
+	}
+
+	return palette
+}
+
+// Framebuffer is a memory-mapped graphical screen with one byte per pixel, starting with the top
+// left pixel and continuing row by row. Pixel bytes are indexes into the framebuffer's palette.
+type Framebuffer struct {
+	width, height int
+
+	mu      sync.Mutex
+	pixels  []byte
+	palette color.Palette
+	changed bool
+}
+
+// NewFramebuffer returns a black framebuffer of the given size, using DefaultPalette
+func NewFramebuffer(width, height int) *Framebuffer {
+	return &Framebuffer{width: width, height: height, pixels: make([]byte, width*height), palette: DefaultPalette}
+}
+
+// Size returns the number of bytes the framebuffer occupies in memory
+func (fb *Framebuffer) Size() int {
+	return fb.width * fb.height
+}
+
+// SetPalette sets the colors of pixel values, which must have between 1 and 256 colors.
+// Pixel values without a color are drawn with the last color.
+func (fb *Framebuffer) SetPalette(palette color.Palette) error {
+	if len(palette) < 1 || len(palette) > 256 {
+		return fmt.Errorf("invalid palette: %d colors (must be between 1 and 256)", len(palette))
+	}
+
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	fb.palette = palette
+	fb.changed = true
+	return nil
+}
+
+func (fb *Framebuffer) Load(offset int) byte {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	return fb.pixels[offset]
+}
+
+func (fb *Framebuffer) Store(offset int, val byte) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if fb.pixels[offset] != val {
+		fb.pixels[offset] = val
+		fb.changed = true
+	}
+}
+
+// Changed reports if the framebuffer changed since the last call
+func (fb *Framebuffer) Changed() bool {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	changed := fb.changed
+	fb.changed = false
+	return changed
+}
+
+// Image returns a snapshot of the framebuffer, with every pixel scaled to scale x scale pixels
+func (fb *Framebuffer) Image(scale int) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	img := image.NewPaletted(image.Rect(0, 0, fb.width*scale, fb.height*scale), fb.palette)
+	last := uint8(len(fb.palette) - 1)
+
+	for y := 0; y < fb.height*scale; y++ {
+		for x := 0; x < fb.width*scale; x++ {
+			val := fb.pixels[(y/scale)*fb.width+x/scale]
+			if val > last {
+				val = last
+			}
+
+			img.Pix[y*img.Stride+x] = val
+		}
+	}
+
+	return img
+}
+
+// WritePNG writes a snapshot of the framebuffer as a PNG image
+func (fb *Framebuffer) WritePNG(w io.Writer, scale int) error {
+	if err := png.Encode(w, fb.Image(scale)); err != nil {
+		return fmt.Errorf("failed to write PNG image: %w", err)
+	}
+
+	return nil
+}
+
+// Animation collects framebuffer snapshots and writes them as an animated GIF
+type Animation struct {
+	delay int // Delay between frames in hundredths of a second
+	anim  gif.GIF
+}
+
+// NewAnimation returns an empty animation, showing each frame for interval. GIF delays are in
+// hundredths of a second, so the interval is rounded up to at least one hundredth, as viewers
+// replace a delay of 0 with their own default.
+func NewAnimation(interval time.Duration) *Animation {
+	delay := int((interval + 10*time.Millisecond - 1) / (10 * time.Millisecond))
+	if delay < 1 {
+		delay = 1
+	}
+
+	return &Animation{delay: delay}
+}
+
+// Add appends a frame to the animation
+func (a *Animation) Add(frame *image.Paletted) {
+	a.anim.Image = append(a.anim.Image, frame)
+	a.anim.Delay = append(a.anim.Delay, a.delay)
+}
+
+// Frames returns the number of frames in the animation
+func (a *Animation) Frames() int {
+	return len(a.anim.Image)
+}
+
+// WriteGIF writes the animation as an animated GIF
+func (a *Animation) WriteGIF(w io.Writer) error {
+	if len(a.anim.Image) == 0 {
+		return fmt.Errorf("animation has no frames")
+	}
+
+	if err := gif.EncodeAll(w, &a.anim); err != nil {
+		return fmt.Errorf("failed to write GIF image: %w", err)
+	}
+
+	return nil
+}
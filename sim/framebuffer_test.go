@@ -0,0 +1,102 @@
+package sim
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// TestFramebufferImage checks scaling, palette lookup and PNG output
+func TestFramebufferImage(t *testing.T) {
+	fb := NewFramebuffer(2, 1)
+	fb.Store(0, 0x30) // Red
+	fb.Store(1, 0x0F) // Green and blue
+
+	if !fb.Changed() || fb.Changed() {
+		t.Error("Changed doesn't report a change exactly once")
+	}
+
+	var buf bytes.Buffer
+
+	if err := fb.WritePNG(&buf, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := img.Bounds().Size(); size.X != 4 || size.Y != 2 {
+		t.Fatalf("image is %dx%d, want 4x2", size.X, size.Y)
+	}
+
+	want := []color.RGBA{{0xFF, 0, 0, 0xFF}, {0xFF, 0, 0, 0xFF}, {0, 0xFF, 0xFF, 0xFF}, {0, 0xFF, 0xFF, 0xFF}}
+
+	for x, c := range want {
+		if got := color.RGBAModel.Convert(img.At(x, 1)).(color.RGBA); got != c {
+			t.Errorf("pixel %d is %v, want %v", x, got, c)
+		}
+	}
+
+	// Pixel values without a color use the last one
+	if err := fb.SetPalette(color.Palette{color.Black, color.White}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fb.Image(1).ColorIndexAt(0, 0); got != 1 {
+		t.Errorf("pixel outside the palette has color %d, want 1", got)
+	}
+
+	if err := fb.SetPalette(nil); err == nil {
+		t.Error("set an empty palette")
+	}
+}
+
+// TestAnimation checks the frames and delays of the GIF output
+func TestAnimation(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		delay    int
+	}{
+		{time.Millisecond, 1},
+		{10 * time.Millisecond, 1},
+		{15 * time.Millisecond, 2},
+		{time.Second, 100},
+	}
+
+	fb := NewFramebuffer(2, 2)
+
+	for _, tt := range tests {
+		anim := NewAnimation(tt.interval)
+
+		var buf bytes.Buffer
+		if err := anim.WriteGIF(&buf); err == nil {
+			t.Error("wrote an animation without frames")
+		}
+
+		anim.Add(fb.Image(1))
+		fb.Store(0, 0x3F)
+		anim.Add(fb.Image(1))
+
+		if err := anim.WriteGIF(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := gif.DecodeAll(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(decoded.Image) != 2 || anim.Frames() != 2 {
+			t.Fatalf("%v: %d frames, want 2", tt.interval, len(decoded.Image))
+		}
+
+		if decoded.Delay[0] != tt.delay {
+			t.Errorf("%v: delay %d, want %d", tt.interval, decoded.Delay[0], tt.delay)
+		}
+	}
+}
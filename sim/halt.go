@@ -0,0 +1,223 @@
+package sim
+
+import (
+	"fmt"
+)
+
+// HaltReason describes why a machine halted
+type HaltReason int
+
+const (
+	HaltNone             HaltReason = iota // Machine has not halted
+	HaltSelfJump                           // A jump instruction jumped to itself
+	HaltAddress                            // PC reached one of the halt addresses
+	HaltInstructionLimit                   // The maximum number of instructions was executed
+	HaltOpcode                             // One of the halt opcodes was fetched
+	HaltSVC                                // An SVC instruction was executed
+	HaltDeviceWrite                        // One of the watched devices was written to
+)
+
+func (r HaltReason) String() string {
+	switch r {
+	case HaltNone:
+		return "not halted"
+	case HaltSelfJump:
+		return "self-jump"
+	case HaltAddress:
+		return "halt address reached"
+	case HaltInstructionLimit:
+		return "instruction limit reached"
+	case HaltOpcode:
+		return "halt opcode fetched"
+	case HaltSVC:
+		return "supervisor call"
+	case HaltDeviceWrite:
+		return "device written"
+	}
+
+	return fmt.Sprintf("unknown halt reason (%d)", int(r))
+}
+
+// Final reports if a machine that halted for this reason can't continue: the program ended with a
+// self-jump or used up its instructions. The machine can resume after other halts, see Resume.
+func (r HaltReason) Final() bool {
+	return r == HaltSelfJump || r == HaltInstructionLimit
+}
+
+// HaltPolicy configures when a machine halts
+type HaltPolicy struct {
+	SelfJump        bool          // Halt when J, JEQ, JGT or JLT jumps to its own address
+	Addresses       []int         // Halt before executing an instruction at one of these addresses
+	Labels          []string      // Like Addresses, but resolved from the machine's symbols
+	MaxInstructions int           // Halt after this many instructions (0 means no limit)
+	Opcodes         []byte        // Halt before executing one of these opcodes
+	SVC             bool          // Halt when an SVC instruction is executed
+	DeviceWrites    map[byte]bool // Halt after writing to one of these devices
+}
+
+// DefaultHaltPolicy returns the policy used by new machines, which only halts on self-jumps
+func DefaultHaltPolicy() HaltPolicy {
+	return HaltPolicy{SelfJump: true}
+}
+
+// HaltPolicy returns the machine's current halt policy
+func (m *Machine) HaltPolicy() HaltPolicy {
+	return m.haltPolicy
+}
+
+// SetHaltPolicy sets the machine's halt policy and clears the halt state. Labels are resolved with
+// the machine's symbols, and again whenever its symbols change, such as when a program is loaded.
+// Unknown labels are only an error once the machine has symbols.
+func (m *Machine) SetHaltPolicy(policy HaltPolicy) error {
+	for _, addr := range policy.Addresses {
+		if !m.isAddr(addr) {
+			return fmt.Errorf("not a valid halt address: %d", addr)
+		}
+	}
+
+	opcodes := make(map[byte]bool)
+
+	for _, opcode := range policy.Opcodes {
+		opcodes[opcode&0xFC] = true
+	}
+
+	m.haltPolicy = policy
+	m.haltOpcodes = opcodes
+	m.clearHalt()
+
+	if unknown := m.resolveHaltLabels(); len(unknown) > 0 && len(m.symbols) > 0 {
+		return fmt.Errorf("unknown halt label: %s", unknown[0])
+	}
+
+	return nil
+}
+
+// resolveHaltLabels sets the halt addresses from the policy's addresses and labels, returning the
+// labels that aren't in the machine's symbols
+func (m *Machine) resolveHaltLabels() []string {
+	addrs := make(map[int]bool)
+	var unknown []string
+
+	for _, addr := range m.haltPolicy.Addresses {
+		addrs[addr] = true
+	}
+
+	for _, label := range m.haltPolicy.Labels {
+		addr, ok := m.symbols[label]
+		if !ok {
+			unknown = append(unknown, label)
+			continue
+		}
+
+		addrs[addr] = true
+	}
+
+	m.haltAddrs = addrs
+	return unknown
+}
+
+// HaltReason returns the reason the machine halted, or HaltNone if it is still running
+func (m *Machine) HaltReason() HaltReason {
+	return m.haltReason
+}
+
+// Symbols returns the machine's symbol table
+func (m *Machine) Symbols() map[string]int {
+	return m.symbols
+}
+
+// SetSymbols sets the symbol table used to resolve labels, including the halt policy's
+func (m *Machine) SetSymbols(symbols map[string]int) {
+	m.symbols = symbols
+	m.labels = make(map[int]string, len(symbols))
+
+	for name, addr := range symbols {
+		if label, ok := m.labels[addr]; !ok || name < label {
+			m.labels[addr] = name
+		}
+	}
+
+	for _, label := range m.resolveHaltLabels() {
+		m.logger.Printf("Unknown halt label: %s\n", label)
+	}
+}
+
+// Instructions returns the number of instructions executed so far
+func (m *Machine) Instructions() int {
+	return m.instructions
+}
+
+// haltWith halts the machine and records the reason
+func (m *Machine) haltWith(reason HaltReason) {
+	m.halted = true
+	m.haltReason = reason
+
+	if m.debug {
+		m.logger.Printf("Halted at 0x%06X: %s\n", m.instAddr, reason)
+	}
+}
+
+// Resume clears a halt the machine can continue after, so that the next instruction executes even
+// if it halted the machine before executing. It reports if the machine isn't halted anymore.
+func (m *Machine) Resume() bool {
+	if !m.halted {
+		return true
+	}
+
+	if m.haltReason.Final() {
+		return false
+	}
+
+	m.clearHalt()
+	m.resumed = true
+	return true
+}
+
+// clearHalt clears the halt state, as if the machine never halted
+func (m *Machine) clearHalt() {
+	m.halted = false
+	m.haltReason = HaltNone
+	m.resumed = false
+}
+
+// haltBefore checks the halt conditions that prevent the next instruction from executing
+func (m *Machine) haltBefore() bool {
+	if m.resumed {
+		m.resumed = false
+		return false
+	}
+
+	if m.haltAddrs[m.instAddr] {
+		m.haltWith(HaltAddress)
+		return true
+	}
+
+	if len(m.haltOpcodes) > 0 {
+		if opcode, err := m.Byte(m.instAddr); err == nil && m.haltOpcodes[opcode&0xFC] {
+			m.haltWith(HaltOpcode)
+			return true
+		}
+	}
+
+	return false
+}
+
+// haltAfter checks the halt conditions that apply once an instruction has executed
+func (m *Machine) haltAfter() {
+	if m.halted {
+		return
+	}
+
+	if limit := m.haltPolicy.MaxInstructions; limit > 0 && m.instructions >= limit {
+		m.haltWith(HaltInstructionLimit)
+	}
+}
+
+// jump sets PC to addr, halting the machine if the jump is a self-jump
+func (m *Machine) jump(addr int) {
+	m.SetPC(addr)
+
+	if m.haltPolicy.SelfJump && addr == m.instAddr {
+		m.haltWith(HaltSelfJump)
+	}
+}
@@ -0,0 +1,114 @@
+package sim
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHaltPolicy checks each halt rule, the reported reason, and resuming after the halt
+func TestHaltPolicy(t *testing.T) {
+	// Memory is zeroed, so the machine executes LDA 0 where no code is given
+	tests := []struct {
+		name   string
+		code   []byte
+		policy HaltPolicy
+		reason HaltReason
+		pc     int
+	}{
+		{"self-jump", []byte{J | 0x03, 0x2F, 0xFD}, DefaultHaltPolicy(), HaltSelfJump, 0},
+		{"address", nil, HaltPolicy{Addresses: []int{6}}, HaltAddress, 6},
+		{"label", nil, HaltPolicy{Labels: []string{"STOP"}}, HaltAddress, 9},
+		{"opcode", []byte{0, 0, 0, STA | 0x03, 0x00, 0x30}, HaltPolicy{Opcodes: []byte{STA}}, HaltOpcode, 3},
+		{"SVC", []byte{SVC, 0x00}, HaltPolicy{SVC: true}, HaltSVC, 2},
+		{"device write", []byte{WD | 0x01, 0x00, 0x01}, HaltPolicy{DeviceWrites: map[byte]bool{1: true}}, HaltDeviceWrite, 3},
+		{"instruction limit", nil, HaltPolicy{MaxInstructions: 4}, HaltInstructionLimit, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Machine
+			m.New()
+			m.AttachDevice(1, NewBufferDevice(nil))
+
+			for i, b := range tt.code {
+				m.SetByte(i, b)
+			}
+
+			if err := m.SetHaltPolicy(tt.policy); err != nil {
+				t.Fatal(err)
+			}
+
+			// Labels are resolved when the symbols are loaded after the policy
+			m.SetSymbols(map[string]int{"STOP": 9})
+
+			res := m.Run(context.Background(), Limits{Steps: 100})
+
+			if res.Reason != StopHalt || res.Halt != tt.reason || m.HaltReason() != tt.reason {
+				t.Fatalf("got %s (%s), want %s", res.Reason, res.Halt, tt.reason)
+			}
+
+			if res.PC != tt.pc {
+				t.Errorf("halted at %06X, want %06X", res.PC, tt.pc)
+			}
+
+			res = m.Run(context.Background(), Limits{Steps: 1})
+
+			if tt.reason.Final() {
+				if res.Reason != StopHalt || res.Instructions != 0 {
+					t.Errorf("rerun after a final halt: got %s after %d instructions", res.Reason, res.Instructions)
+				}
+			} else if res.Reason != StopStepLimit || res.Instructions != 1 {
+				t.Errorf("resumed run: got %s (%s) after %d instructions, want %s", res.Reason, res.Halt, res.Instructions, StopStepLimit)
+			}
+		})
+	}
+}
+
+// TestHaltCleared checks that setting a halt policy or loading a program clears the halt state
+func TestHaltCleared(t *testing.T) {
+	var m Machine
+	m.New()
+	m.SetLogger(log.New(io.Discard, "", 0))
+
+	if err := m.SetHaltPolicy(HaltPolicy{Labels: []string{"STOP"}}); err != nil {
+		t.Errorf("unknown halt label without symbols: %v", err)
+	}
+
+	m.SetSymbols(map[string]int{"START": 0})
+
+	if err := m.SetHaltPolicy(HaltPolicy{Labels: []string{"STOP"}}); err == nil {
+		t.Error("unknown halt label with symbols loaded was accepted")
+	}
+
+	m.SetHaltPolicy(HaltPolicy{MaxInstructions: 1})
+	m.Execute()
+
+	if !m.Halted() || m.Resume() {
+		t.Fatal("machine didn't stay halted after reaching the instruction limit")
+	}
+
+	m.SetHaltPolicy(DefaultHaltPolicy())
+
+	if m.Halted() || m.HaltReason() != HaltNone {
+		t.Error("setting a halt policy didn't clear the halt")
+	}
+
+	m.haltWith(HaltSelfJump)
+	obj := filepath.Join(t.TempDir(), "prog.obj")
+
+	if err := os.WriteFile(obj, []byte("HPROGRA000000000003\nT000000033F2FFD\nE000000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.ParseObjFile(obj); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Halted() {
+		t.Error("loading a program didn't clear the halt")
+	}
+}
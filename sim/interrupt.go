@@ -0,0 +1,129 @@
+package sim
+
+import (
+	"fmt"
+)
+
+// SW register fields. CC keeps its position in the lowest byte, the other fields follow Beck's
+// SIC/XE status word, except ICODE, which is stored in the lowest 6 bits.
+const (
+	SWMode  = 0x800000 // 1 in supervisor mode, 0 in user mode
+	SWIdle  = 0x400000 // 1 if the CPU is idle
+	SWID    = 0x3C0000 // Process identifier
+	SWMask  = 0x00F000 // Interrupt mask, a set bit enables the interrupt class
+	SWCC    = 0x0000C0 // Condition code (LT, EQ, GT)
+	SWICode = 0x00003F // Interruption code
+)
+
+// InterruptClass identifies one of the SIC/XE interrupt classes
+type InterruptClass int
+
+const (
+	InterruptSVC     InterruptClass = 1 // Supervisor call (SVC)
+	InterruptProgram InterruptClass = 2 // Program fault
+	InterruptTimer   InterruptClass = 3 // Interval timer expired
+	InterruptIO      InterruptClass = 4 // I/O channel finished
+)
+
+func (c InterruptClass) String() string {
+	switch c {
+	case InterruptSVC:
+		return "SVC"
+	case InterruptProgram:
+		return "program"
+	case InterruptTimer:
+		return "timer"
+	case InterruptIO:
+		return "I/O"
+	}
+
+	return fmt.Sprintf("unknown interrupt class (%d)", int(c))
+}
+
+// WorkArea returns the address of the interrupt work area of class c.
+//
+// A work area contains the new SW (offset 0) and new PC (offset 3) loaded when the interrupt is
+// taken, followed by the saved status of the interrupted program: SW (6), PC (9), A (12), X (15),
+// L (18), B (21), S (24), T (27) and F (30). The saved status can be restored with LPS.
+func (c InterruptClass) WorkArea() int {
+	return 0x100 + (int(c)-1)*0x30
+}
+
+// mask returns the SW mask bit of class c
+func (c InterruptClass) mask() int {
+	return 0x008000 >> (int(c) - 1)
+}
+
+// CC returns the condition code of the SW register
+func (m *Machine) CC() int {
+	return m.SW() & SWCC
+}
+
+// setCC sets the condition code of the SW register, leaving the other fields untouched
+func (m *Machine) setCC(cc int) {
+	m.SetSW(m.SW()&^SWCC | cc&SWCC)
+}
+
+// compare sets the condition code by comparing a to b
+func (m *Machine) compare(a, b int) {
+	if a > b {
+		m.setCC(GT)
+	} else if a == b {
+		m.setCC(EQ)
+	} else {
+		m.setCC(LT)
+	}
+}
+
+// Supervisor returns true if the machine is in supervisor mode
+func (m *Machine) Supervisor() bool {
+	return m.SW()&SWMode != 0
+}
+
+// interrupt takes an interrupt of class c with interruption code, if the class is enabled in SW.
+// It stores the machine's status in the class' work area and loads the new SW and PC from it.
+func (m *Machine) interrupt(c InterruptClass, code int) bool {
+	if m.SW()&c.mask() == 0 {
+		return false
+	}
+
+	area := c.WorkArea()
+
+	if err := m.storeStatus(area + 6); err != nil {
+		return false
+	}
+
+	sw, _ := m.Word(area)
+	pc, _ := m.Word(area + 3)
+
+	m.SetSW(sw&^SWICode | code&SWICode)
+	m.SetPC(pc)
+
+	if m.debug {
+		m.logger.Printf("Took %s interrupt (code 0x%02X), handler at 0x%06X\n", c, code, pc)
+	}
+
+	return true
+}
+
+// storeStatus stores SW, PC and the registers starting at addr, in the layout LPS loads
+func (m *Machine) storeStatus(addr int) error {
+	vals := []Word{m.regs.sw, m.regs.pc, m.regs.a, m.regs.x, m.regs.l, m.regs.b, m.regs.s, m.regs.t, m.regs.f}
+
+	for i, val := range vals {
+		if err := m.SetWord(addr+3*i, val.Int()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadStatus loads SW, PC and the registers starting at addr (LPS)
+func (m *Machine) loadStatus(addr int) {
+	regs := []*Word{&m.regs.sw, &m.regs.pc, &m.regs.a, &m.regs.x, &m.regs.l, &m.regs.b, &m.regs.s, &m.regs.t, &m.regs.f}
+
+	for i, reg := range regs {
+		*reg = NewWord(m.readWord(addr + 3*i))
+	}
+}
@@ -0,0 +1,142 @@
+package sim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DeviceState is the state of a device reported by TD
+type DeviceState int
+
+const (
+	DeviceReady DeviceState = iota // TD sets CC to LT
+	DeviceBusy                     // TD sets CC to EQ
+	DeviceEOF                      // TD sets CC to GT, the device has no more input
+)
+
+func (s DeviceState) String() string {
+	switch s {
+	case DeviceReady:
+		return "ready"
+	case DeviceBusy:
+		return "busy"
+	case DeviceEOF:
+		return "end of input"
+	}
+
+	return fmt.Sprintf("unknown device state (%d)", int(s))
+}
+
+// cc returns the condition code TD sets for the state
+func (s DeviceState) cc() int {
+	switch s {
+	case DeviceReady:
+		return LT
+	case DeviceEOF:
+		return GT
+	}
+
+	return EQ
+}
+
+// EOFReporter is implemented by devices that can report reaching the end of their input
+type EOFReporter interface {
+	EOF() bool
+}
+
+// Latency is the time a device stays busy after each RD or WD. Reading from or writing to a busy
+// device raises a device fault, so programs must wait for it with TD.
+type Latency struct {
+	Instructions int // Number of instructions executed after the access
+	Cycles       int // Number of cycles counted from the start of the access
+}
+
+// busyUntil is the time at which a device becomes ready again
+type busyUntil struct {
+	instructions int
+	cycles       int
+}
+
+// ParseDeviceLatency parses a latency in the form ID=n, in instructions, or ID=nc, in cycles
+func ParseDeviceLatency(spec string) (byte, Latency, error) {
+	var lat Latency
+
+	eq := strings.IndexRune(spec, '=')
+	if eq < 0 {
+		return 0, lat, fmt.Errorf("invalid device latency '%s': expected ID=n or ID=nc", spec)
+	}
+
+	id, err := strconv.ParseUint(strings.TrimSpace(spec[:eq]), 16, 8)
+	if err != nil {
+		return 0, lat, fmt.Errorf("invalid device ID in latency '%s': %w", spec, err)
+	}
+
+	val := strings.TrimSpace(spec[eq+1:])
+	cycles := strings.HasSuffix(val, "c")
+
+	n, err := strconv.Atoi(strings.TrimSuffix(val, "c"))
+	if err != nil || n < 0 {
+		return 0, lat, fmt.Errorf("invalid device latency '%s': expected a non-negative number", spec)
+	}
+
+	if cycles {
+		lat.Cycles = n
+	} else {
+		lat.Instructions = n
+	}
+
+	return byte(id), lat, nil
+}
+
+// DeviceLatency returns the latency of device id
+func (m *Machine) DeviceLatency(id byte) Latency {
+	return m.latency[id]
+}
+
+// SetDeviceLatency sets the time device id stays busy after each access
+func (m *Machine) SetDeviceLatency(id byte, lat Latency) error {
+	if lat.Instructions < 0 || lat.Cycles < 0 {
+		return fmt.Errorf("invalid latency for device %02X: must not be negative", id)
+	}
+
+	m.latency[id] = lat
+	return nil
+}
+
+// DeviceState returns the state of device id, as tested by TD
+func (m *Machine) DeviceState(id byte) DeviceState {
+	if m.deviceBusy(id) {
+		return DeviceBusy
+	}
+
+	dev := m.device(id)
+
+	if r, ok := dev.(EOFReporter); ok && r.EOF() {
+		return DeviceEOF
+	}
+
+	if !dev.Test() {
+		return DeviceBusy
+	}
+
+	return DeviceReady
+}
+
+// deviceBusy reports if device id is still busy after its last access
+func (m *Machine) deviceBusy(id byte) bool {
+	return m.instructions < m.busy[id].instructions || m.cycles < m.busy[id].cycles
+}
+
+// accessDevice marks device id as busy for its latency, starting with the current instruction
+func (m *Machine) accessDevice(id byte) {
+	lat := m.latency[id]
+
+	if lat.Instructions > 0 {
+		m.busy[id].instructions = m.instructions + 1 + lat.Instructions
+	}
+
+	if lat.Cycles > 0 {
+		m.busy[id].cycles = m.cycles + lat.Cycles
+	}
+}
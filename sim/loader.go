@@ -7,7 +7,7 @@ import (
 	"strconv"
 )
 
-func parseString(r *bufio.Reader, len int) string {
+func (m *Machine) parseString(r *bufio.Reader, len int) string {
 	buf := make([]rune, len)
 
 	for i := 0; i < len; i++ {
@@ -15,24 +15,24 @@ func parseString(r *bufio.Reader, len int) string {
 		buf[i] = char
 	}
 
-	if debug {
-		fmt.Printf("String: %q\n", string(buf))
+	if m.debug {
+		m.logger.Printf("String: %q\n", string(buf))
 	}
 
 	return string(buf)
 }
 
-func parseRune(r *bufio.Reader) rune {
+func (m *Machine) parseRune(r *bufio.Reader) rune {
 	char, _, _ := r.ReadRune()
 
-	if debug {
-		fmt.Printf("Char: %q\n", char)
+	if m.debug {
+		m.logger.Printf("Char: %q\n", char)
 	}
 
 	return char
 }
 
-func parseWord(r *bufio.Reader) int {
+func (m *Machine) parseWord(r *bufio.Reader) int {
 	buf := make([]rune, 6)
 
 	for i := 0; i < 6; i++ {
@@ -45,15 +45,15 @@ func parseWord(r *bufio.Reader) int {
 		panic(err)
 	}
 
-	if debug {
-		fmt.Printf("Word (before): %q\n", string(buf))
-		fmt.Printf("Word (after): %06X\n", word)
+	if m.debug {
+		m.logger.Printf("Word (before): %q\n", string(buf))
+		m.logger.Printf("Word (after): %06X\n", word)
 	}
 
 	return int(word)
 }
 
-func parseByte(r *bufio.Reader) byte {
+func (m *Machine) parseByte(r *bufio.Reader) byte {
 	buf := make([]rune, 2)
 
 	for i := 0; i < 2; i++ {
@@ -66,9 +66,9 @@ func parseByte(r *bufio.Reader) byte {
 		panic(err)
 	}
 
-	if debug {
-		fmt.Printf("Byte (before): %q\n", string(buf))
-		fmt.Printf("Byte (after): %02X\n", bytes)
+	if m.debug {
+		m.logger.Printf("Byte (before): %q\n", string(buf))
+		m.logger.Printf("Byte (after): %02X\n", bytes)
 	}
 
 	return byte(bytes)
@@ -82,77 +82,81 @@ func (m *Machine) ParseObjFile(objFile string) error {
 
 	defer file.Close()
 
+	m.clearHalt()
 	reader := bufio.NewReader(file)
 
-	if debug {
-		fmt.Println("--- Start ParseObj ---")
+	if m.debug {
+		m.logger.Println("--- Start ParseObj ---")
 	}
 
-	rec := parseRune(reader)
+	rec := m.parseRune(reader)
 
 	// Header record
 	if rec != 'H' {
 		return fmt.Errorf("failed to parse object file: no header record")
 	}
 
-	progName := parseString(reader, 6)
-	startAddr := parseWord(reader)
-	codeLen := parseWord(reader)
+	progName := m.parseString(reader, 6)
+	startAddr := m.parseWord(reader)
+	codeLen := m.parseWord(reader)
 
-	if debug {
-		fmt.Println("[Header]")
-		fmt.Println("    name: " + progName)
-		fmt.Println("    addr: " + printWord(startAddr))
-		fmt.Println("    len: " + printWord(codeLen))
+	if m.debug {
+		m.logger.Println("[Header]")
+		m.logger.Println("    name: " + progName)
+		m.logger.Println("    addr: " + printWord(startAddr))
+		m.logger.Println("    len: " + printWord(codeLen))
 	}
 
 	// Seek to a new line and parse the record type
 	reader.ReadLine()
-	rec = parseRune(reader)
+	rec = m.parseRune(reader)
 
 	// Text records
 	for rec == 'T' {
-		addr := parseWord(reader)
-		len := parseByte(reader)
+		addr := m.parseWord(reader)
+		len := m.parseByte(reader)
 
-		if debug {
-			fmt.Println("[Text]")
-			fmt.Println("    addr: " + printWord(addr))
-			fmt.Println("    len: " + printByte(len))
+		if m.debug {
+			m.logger.Println("[Text]")
+			m.logger.Println("    addr: " + printWord(addr))
+			m.logger.Println("    len: " + printByte(len))
 		}
 
 		for i := 0; i < int(len); i++ {
-			val := parseByte(reader)
-			m.SetByte(addr, val)
+			val := m.parseByte(reader)
+			if err := m.SetByte(addr, val); err != nil {
+				return fmt.Errorf("failed to parse object file: %w", err)
+			}
+
 			addr++
 		}
 
 		reader.ReadLine()
-		rec = parseRune(reader)
+		rec = m.parseRune(reader)
 	}
 
 	// Modification records
 	for rec == 'M' {
-		offset := parseWord(reader)
-		len := parseByte(reader)
+		offset := m.parseWord(reader)
+		len := m.parseByte(reader)
 
 		// TODO: Implement reading long version
 		// operator := ParseStringReader(reader, 1)
 		// name := ParseStringReader(reader, 6)
 
-		if debug {
-			fmt.Println("[Modification]")
-			fmt.Println("    offset: " + printWord(offset))
-			fmt.Println("    len: " + printByte(len))
+		if m.debug {
+			m.logger.Println("[Modification]")
+			m.logger.Println("    offset: " + printWord(offset))
+			m.logger.Println("    len: " + printByte(len))
 
 			// if long {
-			// 	fmt.Println("    operator: " + operator)
-			// 	fmt.Println("    symbol name: " + name)
+			// 	m.logger.Println("    operator: " + operator)
+			// 	m.logger.Println("    symbol name: " + name)
 			// }
 		}
 
 		reader.ReadLine()
-		rec = parseRune(reader)
+		rec = m.parseRune(reader)
 	}
 
 	// End record
@@ -160,11 +164,12 @@ func (m *Machine) ParseObjFile(objFile string) error {
 		return fmt.Errorf("failed to parse object file: no end record")
 	}
 
-	m.SetPC(parseWord(reader))
+	m.SetPC(m.parseWord(reader))
 
-	if debug {
-		fmt.Println("--- End ParseObj ---")
+	if m.debug {
+		m.logger.Println("--- End ParseObj ---")
 	}
 
+	m.loadObjDebugInfo(objFile, progName, startAddr, codeLen)
 	return nil
 }
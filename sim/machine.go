@@ -2,91 +2,271 @@ package sim
 
 import (
 	"fmt"
+	"io"
 	"log"
-	"time"
+	"os"
+
+	"github.com/erazemk/sicsim/debuginfo"
 )
 
+// MAX_ADDRESS is the size of the SIC/XE address space, see Profile for the size of a machine's memory
 const MAX_ADDRESS = 1048576
 
 // Stack for JSUB and RSUB instructions
 type stack []int
 
 type Machine struct {
-	regs        registers
-	mem         [MAX_ADDRESS + 1]byte
-	devs        [256](*device)
-	stack       stack
-	tick        time.Duration
-	ticker      *time.Ticker
-	halted      bool
-	interactive bool
+	regs   registers
+	mem    []byte
+	devs   [256]Device
+	stack  stack
+	halted bool
+
+	latency [256]Latency   // Time devices stay busy after each access
+	busy    [256]busyUntil // Time at which busy devices become ready
+
+	profile Profile
+	regions []mappedRegion // Memory-mapped I/O regions
+
+	speed   int     // Target clock frequency in Hz, 0 means unthrottled
+	ips     float64 // Instructions per second measured during the last run
+	running int32   // Set while Start is executing instructions
+	stopped int32   // Set by Stop to interrupt Start
+
+	timing Timing
+	cycles int
+
+	timer        int  // Cycles left until the interval timer expires, 0 if it isn't running
+	timerPending bool // The interval timer expired and the expiry hasn't been handled yet
+	timerSet     bool // The current instruction set the interval timer, so its cycles don't count
+
+	channels  [Channels]channel
+	ioPending int // Bit n is set if channel n finished and its I/O interrupt wasn't taken yet
+
+	breakpoints map[int]bool
+	profiler    *Profiler
+	coverage    *Coverage
+
+	overflowTrap bool
+
+	fault   *Fault // First fault raised by the current instruction
+	fetched int    // Number of bytes fetched by the current instruction
+
+	debug  bool
+	logger *log.Logger
+	stdin  io.Reader // Backs device 0
+	stdout io.Writer // Backs device 1
+	stderr io.Writer // Backs device 2
+	devDir string    // Directory holding the XX.dev files of other devices
+
+	instAddr     int // Address of the instruction being executed
+	instructions int // Number of executed instructions
+
+	haltPolicy  HaltPolicy
+	haltAddrs   map[int]bool
+	haltOpcodes map[byte]bool
+	haltReason  HaltReason
+	resumed     bool // The machine resumed after a halt, the next instruction doesn't halt before executing
+	symbols     map[string]int
+	labels      map[int]string // Label of each symbol address, see Label
+	debugInfo   *debuginfo.Info
 }
 
-// New creates a new machine
+// New creates a new machine, wired to the process' standard streams and working directory.
+// Devices are created on first use, so the wiring can be changed before the program runs.
 func (m *Machine) New() {
-	m.NewDevice(0) // stdin
-	m.NewDevice(1) // stdout
-	m.NewDevice(2) // stderr
+	_, m.debug = os.LookupEnv("SICSIM_DEBUG")
+	m.logger = log.New(os.Stderr, "", log.LstdFlags)
+	m.stdin = os.Stdin
+	m.stdout = os.Stdout
+	m.stderr = os.Stderr
+	m.devDir = "."
 	m.stack = stack{}
-	m.tick = time.Millisecond // Default clock duration
-	m.ticker = nil
+	m.symbols = make(map[string]int)
+	m.breakpoints = make(map[int]bool)
+	m.timing = DefaultTiming()
+	m.regs.sw = SWMode
+	m.SetProfile(ProfileXE)
+	m.SetHaltPolicy(DefaultHaltPolicy())
 
-	if debug {
-		log.Println("Created a new machine")
+	if m.debug {
+		m.logger.Println("Created a new machine")
 	}
 }
 
+// SetOverflowTrap enables or disables raising a fault on arithmetic overflow instead of wrapping
+func (m *Machine) SetOverflowTrap(trap bool) {
+	m.overflowTrap = trap
+}
+
+// SetDebug enables or disables debug output
+func (m *Machine) SetDebug(debug bool) {
+	m.debug = debug
+}
+
+// SetLogger sets the logger used for debug output and device errors
+func (m *Machine) SetLogger(logger *log.Logger) {
+	m.logger = logger
+}
+
+// SetStdin sets the reader backing the default device 0
+func (m *Machine) SetStdin(r io.Reader) {
+	m.stdin = r
+	m.closeDevice(0)
+}
+
+// SetStdout sets the writer backing the default device 1
+func (m *Machine) SetStdout(w io.Writer) {
+	m.stdout = w
+	m.closeDevice(1)
+}
+
+// SetStderr sets the writer backing the default device 2
+func (m *Machine) SetStderr(w io.Writer) {
+	m.stderr = w
+	m.closeDevice(2)
+}
+
+// SetDeviceDir sets the directory holding the files of the default devices 3-255
+func (m *Machine) SetDeviceDir(dir string) {
+	m.devDir = dir
+}
+
 // Returns true if execution has halted
 func (m *Machine) Halted() bool {
 	return m.halted
 }
 
-func (m *Machine) SetInteractive(interactive bool) {
-	m.interactive = interactive
+// TestDevice reports if device id is ready, see DeviceState for the reason it isn't
+func (m *Machine) TestDevice(id byte) bool {
+	return m.DeviceState(id) == DeviceReady
 }
 
-func (m *Machine) TestDevice(id byte) bool {
-	if m.devs[id] != nil {
-		return m.devs[id].test()
+// ReadDevice reads a byte from device id. Reading past the end of input returns an error
+// wrapping io.EOF.
+func (m *Machine) ReadDevice(id byte) (byte, error) {
+	if m.deviceBusy(id) {
+		return 0, fmt.Errorf("failed to read from device %02X: device is busy", id)
 	}
 
-	m.NewDevice(id)
-	return m.devs[id].test()
-}
+	val, err := m.device(id).Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read from device %02X: %w", id, err)
+	}
 
-func (m *Machine) ReadDevice(id byte) (byte, error) {
-	if m.devs[id] != nil {
-		return m.devs[id].read()
+	m.accessDevice(id)
+
+	if m.debug {
+		m.logger.Printf("Read byte '%c' from device %02X\n", val, id)
 	}
 
-	m.NewDevice(id)
-	return m.devs[id].read()
+	return val, nil
 }
 
+// WriteDevice writes val to device id
 func (m *Machine) WriteDevice(id, val byte) error {
-	if m.devs[id] != nil {
-		return m.devs[id].write(val)
+	if m.deviceBusy(id) {
+		return fmt.Errorf("failed to write to device %02X: device is busy", id)
 	}
 
-	m.NewDevice(id)
-	return m.devs[id].write(val)
+	if err := m.device(id).Write(val); err != nil {
+		return fmt.Errorf("failed to write to device %02X: %w", id, err)
+	}
+
+	m.accessDevice(id)
+
+	if m.debug {
+		m.logger.Printf("Wrote byte '%c' to device %02X\n", val, id)
+	}
+
+	return nil
 }
 
+// NewDevice creates the default device for id, see AttachDevice for using other devices
 func (m *Machine) NewDevice(id byte) error {
 	if m.devs[id] != nil {
-		return fmt.Errorf("device '%s' already exists", m.devs[id].name)
+		return fmt.Errorf("device %02X already exists", id)
 	}
 
-	dev, err := newDevice(id)
+	m.devs[id] = m.newDevice(id)
 
-	if err != nil {
-		return err
+	if m.debug {
+		m.logger.Printf("Added default device %02X\n", id)
 	}
 
+	return nil
+}
+
+// AttachDevice replaces device id with dev, closing the replaced device
+func (m *Machine) AttachDevice(id byte, dev Device) {
+	m.closeDevice(id)
 	m.devs[id] = dev
+
+	if m.debug {
+		m.logger.Printf("Attached device %02X: %T\n", id, dev)
+	}
+}
+
+// DetachDevice closes and removes device id, so the default device is created on its next use
+func (m *Machine) DetachDevice(id byte) {
+	m.closeDevice(id)
+}
+
+// closeDevice closes device id if it implements io.Closer and removes it
+func (m *Machine) closeDevice(id byte) {
+	if c, ok := m.devs[id].(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			m.logger.Printf("Failed to close device %02X: %v\n", id, err)
+		}
+	}
+
+	m.devs[id] = nil
+}
+
+// Device returns device id, creating the default device if none is attached
+func (m *Machine) Device(id byte) Device {
+	return m.device(id)
+}
+
+// device returns device id, creating the default device on first use
+func (m *Machine) device(id byte) Device {
+	if m.devs[id] == nil {
+		m.NewDevice(id)
+	}
+
+	return m.devs[id]
+}
+
+// ResetDevices makes all devices ready and resets the ones that implement Resetter
+func (m *Machine) ResetDevices() error {
+	m.busy = [256]busyUntil{}
+
+	for id, dev := range m.devs {
+		if r, ok := dev.(Resetter); ok {
+			if err := r.Reset(); err != nil {
+				return fmt.Errorf("failed to reset device %02X: %w", id, err)
+			}
+		}
+	}
+
 	return nil
 }
 
+// Close closes all devices that implement io.Closer
+func (m *Machine) Close() error {
+	var err error
+
+	for id, dev := range m.devs {
+		if c, ok := dev.(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("failed to close device %02X: %w", id, cerr)
+			}
+		}
+	}
+
+	return err
+}
+
 func (m *Machine) push(val int) {
 	m.stack = append(m.stack, val)
 }
@@ -0,0 +1,71 @@
+package sim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentMachines runs many machines in parallel, each with its own devices and logger
+func TestConcurrentMachines(t *testing.T) {
+	const machines = 200
+
+	var wg sync.WaitGroup
+	errs := make(chan error, machines)
+
+	for i := 0; i < machines; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			var m Machine
+			var stdout bytes.Buffer
+
+			m.New()
+			m.SetDebug(i%10 == 0)
+			m.SetLogger(log.New(io.Discard, "", 0))
+			m.SetStdout(&stdout)
+			m.SetDeviceDir(t.TempDir())
+
+			obj, want := "print.obj", "SIC/XE\x00\x00\n"
+
+			if i%2 == 1 {
+				obj, want = "cat.obj", fmt.Sprintf("machine %d", i)
+				m.SetStdin(strings.NewReader(want))
+			}
+
+			if err := m.ParseObjFile("../examples/obj/" + obj); err != nil {
+				errs <- err
+				return
+			}
+
+			for steps := 0; !m.Halted(); steps++ {
+				if steps > 10000 {
+					errs <- fmt.Errorf("machine %d (%s) did not halt", i, obj)
+					return
+				}
+
+				if err := m.Execute(); err != nil {
+					errs <- fmt.Errorf("machine %d (%s): %w", i, obj, err)
+					return
+				}
+			}
+
+			if got := stdout.String(); got != want {
+				errs <- fmt.Errorf("machine %d (%s): got output %q, want %q", i, obj, got, want)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
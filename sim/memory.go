@@ -1,15 +1,14 @@
 package sim
 
 import (
-	"encoding/binary"
 	"fmt"
 	"strings"
 )
 
 // Byte returns the byte at m[addr]
-func (m Machine) Byte(addr int) (byte, error) {
-	if isAddr(addr) {
-		return m.mem[addr], nil
+func (m *Machine) Byte(addr int) (byte, error) {
+	if m.isAddr(addr) {
+		return m.load(addr), nil
 	}
 
 	return 0, fmt.Errorf("not a valid address: %d", addr)
@@ -17,51 +16,120 @@ func (m Machine) Byte(addr int) (byte, error) {
 
 // SetByte sets the byte at the address addr to val
 func (m *Machine) SetByte(addr int, val byte) error {
-	if isAddr(addr) {
-		m.mem[addr] = val
+	if m.isAddr(addr) {
+		m.store(addr, val)
 		return nil
 	}
 
 	return fmt.Errorf("not a valid address: %d", addr)
 }
 
-// Word returns the word at m[addr..addr+2]
-func (m Machine) Word(addr int) (int, error) {
-	if isAddr(addr) {
-		buf := []byte{0, m.mem[addr], m.mem[addr+1], m.mem[addr+2]}
-		word := int(binary.BigEndian.Uint32(buf))
-		return word, nil
+// Word returns the signed word at m[addr..addr+2]
+func (m *Machine) Word(addr int) (int, error) {
+	if err := m.checkWordAddr(addr); err != nil {
+		return 0, err
 	}
 
-	return 0, fmt.Errorf("not a valid address: %d", addr)
+	return WordFromBytes(m.load(addr), m.load(addr+1), m.load(addr+2)).Int(), nil
 }
 
-// SetWord sets the word (3 bytes) at addr to val
+// SetWord sets the word (3 bytes) at addr to val, which may be signed or unsigned
 func (m *Machine) SetWord(addr, val int) error {
-	if isAddr(addr) && isWord(val) {
-		buf := make([]byte, 4)
-		binary.BigEndian.PutUint32(buf, uint32(val))
-
-		// buf[0] == MSB, which is too big for SIC words, so it isn't used
-		m.mem[addr] = buf[1]
-		m.mem[addr+1] = buf[2]
-		m.mem[addr+2] = buf[3]
-		return nil
+	if err := m.checkWordAddr(addr); err != nil {
+		return err
+	}
+
+	if !isWord(val) {
+		return fmt.Errorf("not a valid word: %d", val)
+	}
+
+	for i, b := range NewWord(val).Bytes() {
+		m.store(addr+i, b)
+	}
+
+	return nil
+}
+
+// checkWordAddr checks that all 3 bytes of the word at addr are inside memory
+func (m *Machine) checkWordAddr(addr int) error {
+	if !m.isAddr(addr) {
+		return fmt.Errorf("not a valid address: %d", addr)
+	}
+
+	if !m.isAddr(addr + 2) {
+		return fmt.Errorf("word at address %d straddles the end of memory (%d bytes)", addr, len(m.mem))
+	}
+
+	return nil
+}
+
+// checkRange checks that the n bytes starting at addr are inside memory
+func (m *Machine) checkRange(addr, n int) error {
+	if n < 0 || !m.isAddr(addr) || (n > 0 && !m.isAddr(addr+n-1)) {
+		return fmt.Errorf("not a valid memory range: 0x%06X-0x%06X (memory has %d bytes)", addr, addr+n-1, len(m.mem))
+	}
+
+	return nil
+}
+
+// ReadMem returns the n bytes starting at addr
+func (m *Machine) ReadMem(addr, n int) ([]byte, error) {
+	if err := m.checkRange(addr, n); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = m.load(addr + i)
+	}
+
+	return data, nil
+}
+
+// WriteMem copies data to memory starting at addr. Nothing is written if data doesn't fit.
+func (m *Machine) WriteMem(addr int, data []byte) error {
+	if err := m.checkRange(addr, len(data)); err != nil {
+		return err
+	}
+
+	for i, val := range data {
+		m.store(addr+i, val)
 	}
 
-	return fmt.Errorf("not a valid address or value: %d, %d", addr, val)
+	return nil
 }
 
-// Mem prints the content of the memory from startAddr to endAddr
-func (m *Machine) Mem(startAddr, endAddr int) string {
+// FillMem sets the n bytes starting at addr to val
+func (m *Machine) FillMem(addr, n int, val byte) error {
+	if err := m.checkRange(addr, n); err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		m.store(addr+i, val)
+	}
+
+	return nil
+}
+
+// Mem prints the content of the memory from startAddr up to endAddr
+func (m *Machine) Mem(startAddr, endAddr int) (string, error) {
+	data, err := m.ReadMem(startAddr, endAddr-startAddr)
+	if err != nil {
+		return "", err
+	}
+
 	var sb strings.Builder
+	sb.WriteString("[")
 
-	sb.WriteString("[" + fmt.Sprintf("%02X", m.mem[startAddr]))
+	for i, val := range data {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
 
-	for _, val := range m.mem[startAddr+1 : endAddr] {
-		sb.WriteString(fmt.Sprintf(" %02X", val))
+		sb.WriteString(fmt.Sprintf("%02X", val))
 	}
 
 	sb.WriteString("]")
-	return sb.String()
+	return sb.String(), nil
 }
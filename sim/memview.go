@@ -0,0 +1,181 @@
+package sim
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/erazemk/sicsim/debuginfo"
+)
+
+// bytesPerRow is the number of bytes in a row of HexDump
+const bytesPerRow = 16
+
+// HexDump returns the memory from addr up to addr+n in rows of 16 bytes, each starting with its
+// address and ending with the bytes as ASCII characters. Rows are aligned to 16 bytes, the bytes
+// outside the range are left blank.
+func (m *Machine) HexDump(addr, n int) ([]string, error) {
+	data, err := m.ReadMem(addr, n)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []string
+
+	for row := addr &^ (bytesPerRow - 1); row < addr+n; row += bytesPerRow {
+		var hex, ascii strings.Builder
+
+		for i := 0; i < bytesPerRow; i++ {
+			if i == bytesPerRow/2 {
+				hex.WriteByte(' ')
+			}
+
+			if a := row + i; a < addr || a >= addr+n {
+				hex.WriteString("   ")
+				ascii.WriteByte(' ')
+			} else {
+				val := data[a-addr]
+				fmt.Fprintf(&hex, "%02X ", val)
+
+				if val >= 0x20 && val < 0x7F {
+					ascii.WriteByte(val)
+				} else {
+					ascii.WriteByte('.')
+				}
+			}
+		}
+
+		rows = append(rows, fmt.Sprintf("%06X  %s |%s|", row, hex.String(), ascii.String()))
+	}
+
+	return rows, nil
+}
+
+// WordDump returns n words starting at addr, with their labels and values in hex, unsigned and signed
+func (m *Machine) WordDump(addr, n int) ([]string, error) {
+	if err := m.checkRange(addr, 3*n); err != nil {
+		return nil, err
+	}
+
+	rows := []string{fmt.Sprintf("%-6s  %-10s %-6s %10s %10s", "Addr", "Label", "Hex", "Unsigned", "Signed")}
+
+	for i := 0; i < n; i++ {
+		a := addr + 3*i
+		word, _ := m.Word(a)
+		rows = append(rows, fmt.Sprintf("%06X  %-10s %06X %10d %10d", a, m.Label(a), word&0xFFFFFF, word&0xFFFFFF, word))
+	}
+
+	return rows, nil
+}
+
+// FloatDump returns n 48-bit floats starting at addr, with their labels, bytes and values
+func (m *Machine) FloatDump(addr, n int) ([]string, error) {
+	if err := m.checkRange(addr, 6*n); err != nil {
+		return nil, err
+	}
+
+	rows := []string{fmt.Sprintf("%-6s  %-10s %-12s  %s", "Addr", "Label", "Hex", "Value")}
+
+	for i := 0; i < n; i++ {
+		a := addr + 6*i
+		val, _ := m.Float(a)
+		data, _ := m.ReadMem(a, 6)
+		rows = append(rows, fmt.Sprintf("%06X  %-10s %012X  %g", a, m.Label(a), data, val))
+	}
+
+	return rows, nil
+}
+
+// DisasmDump returns n instructions starting at addr in assembler syntax, with their labels and,
+// if the machine has debug info, their source lines. It stops early at the end of memory.
+func (m *Machine) DisasmDump(addr, n int) ([]string, error) {
+	if !m.isAddr(addr) {
+		return nil, fmt.Errorf("not a valid address: %d", addr)
+	}
+
+	var rows []string
+
+	for i := 0; i < n; i++ {
+		inst, size, err := m.Disassemble(addr)
+		if err != nil {
+			break
+		}
+
+		if line, ok := m.SourceLine(addr); ok {
+			rows = append(rows, fmt.Sprintf("%06X  %-10s %-24s line %d", addr, m.Label(addr), inst, line))
+		} else {
+			rows = append(rows, fmt.Sprintf("%06X  %-10s %s", addr, m.Label(addr), inst))
+		}
+
+		addr += size
+	}
+
+	return rows, nil
+}
+
+// LabelDump returns the symbols with values from low up to high and what they label: the
+// instruction at code labels, the word or first bytes at other labels. Data types and sizes
+// come from the debug info.
+func (m *Machine) LabelDump(low, high int) []string {
+	rows := []string{fmt.Sprintf("%-6s  %-10s %-5s %5s  %s", "Addr", "Label", "Type", "Size", "Contents")}
+
+	for _, sym := range m.sortedSymbols() {
+		if sym.addr < low || sym.addr >= high {
+			continue
+		}
+
+		typ, size, contents := "", "-", ""
+
+		if d, ok := m.labelledData(sym); ok {
+			typ, size = d.Type, fmt.Sprintf("%d", d.Size)
+
+			if d.Size != 3 {
+				shown := d.Size
+				if shown > 8 {
+					shown = 8
+				}
+
+				if contents, _ = m.Mem(sym.addr, sym.addr+shown); shown < d.Size {
+					contents += " ..."
+				}
+			}
+		} else if m.isCode(sym.addr) {
+			typ = "code"
+			contents, _, _ = m.Disassemble(sym.addr)
+		}
+
+		if contents == "" {
+			if word, err := m.Word(sym.addr); err == nil {
+				contents = fmt.Sprintf("%06X (Dec: %d)", word&0xFFFFFF, word)
+			}
+		}
+
+		rows = append(rows, fmt.Sprintf("%06X  %-10s %-5s %5s  %s", sym.addr, sym.name, typ, size, contents))
+	}
+
+	return rows
+}
+
+// labelledData returns the data item labelled by sym in the debug info
+func (m *Machine) labelledData(sym symbol) (debuginfo.Data, bool) {
+	if m.debugInfo == nil {
+		return debuginfo.Data{}, false
+	}
+
+	d, ok := m.debugInfo.DataAt(sym.addr)
+	return d, ok && d.Name == sym.name
+}
+
+// isCode reports if the debug info has an instruction at addr
+func (m *Machine) isCode(addr int) bool {
+	if m.debugInfo == nil {
+		return false
+	}
+
+	for _, l := range m.debugInfo.Lines {
+		if l.Addr == addr {
+			return l.Code
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erazemk/sicsim/debuginfo"
+)
+
+// checkRows compares the rows of a memory view
+func checkRows(t *testing.T, name string, got, want []string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d rows %q, want %d", name, len(got), got, len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: row %d is\n%q, want\n%q", name, i, got[i], want[i])
+		}
+	}
+}
+
+// TestHexDump checks the row alignment, the blanks outside the range and the ASCII column
+func TestHexDump(t *testing.T) {
+	var m Machine
+	m.New()
+	m.WriteMem(0x0E, []byte{'A', 'B', 0x00, 0x7F, 'z'})
+
+	blank := func(n int) string { return strings.Repeat("   ", n) }
+
+	tests := []struct {
+		addr, n int
+		want    []string
+	}{
+		{0x0E, 5, []string{
+			"000000  " + blank(8) + " " + blank(6) + "41 42  |              AB|",
+			"000010  00 7F 7A " + blank(5) + " " + blank(8) + " |..z             |",
+		}},
+		{0x10, 2, []string{
+			"000010  00 7F " + blank(6) + " " + blank(8) + " |..              |",
+		}},
+		{0x00, 0, nil},
+	}
+
+	for _, tt := range tests {
+		rows, err := m.HexDump(tt.addr, tt.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkRows(t, "HexDump", rows, tt.want)
+	}
+
+	if _, err := m.HexDump(0x0FFFFF, 2); err == nil {
+		t.Error("dumped memory past the end")
+	}
+}
+
+// TestWordAndFloatDump checks the labels and the unsigned, signed and float values
+func TestWordAndFloatDump(t *testing.T) {
+	var m Machine
+	m.New()
+	m.SetSymbols(map[string]int{"DATA": 0x30, "ALIAS": 0x30, "ONE": 0x40})
+	m.SetWord(0x30, 0xFFFFFE)
+	m.WriteMem(0x40, []byte{0x40, 0x18, 0, 0, 0, 0})
+	m.WriteMem(0x46, []byte{0xC0, 0x2A, 0, 0, 0, 0})
+
+	rows, err := m.WordDump(0x30, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRows(t, "WordDump", rows, []string{
+		"Addr    Label      Hex      Unsigned     Signed",
+		"000030  ALIAS      FFFFFE   16777214         -2",
+		"000033             000000          0          0",
+	})
+
+	rows, err = m.FloatDump(0x40, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRows(t, "FloatDump", rows, []string{
+		"Addr    Label      Hex           Value",
+		"000040  ONE        401800000000  1",
+		"000046             C02A00000000  -2.5",
+	})
+
+	if _, err := m.WordDump(0x0FFFFE, 1); err == nil {
+		t.Error("dumped words past the end of memory")
+	}
+
+	if _, err := m.FloatDump(0x0FFFFC, 1); err == nil {
+		t.Error("dumped floats past the end of memory")
+	}
+}
+
+// TestLabelDump checks the types, sizes and contents of code and data labels
+func TestLabelDump(t *testing.T) {
+	var m Machine
+	m.New()
+	m.WriteMem(0x00, []byte{0x01, 0x00, 0x05})
+	m.WriteMem(0x10, []byte("0123456789"))
+	m.SetWord(0x1A, 7)
+
+	m.SetDebugInfo(&debuginfo.Info{
+		Symbols: []debuginfo.Symbol{
+			{Name: "MAIN", Value: 0x00},
+			{Name: "TEXT", Value: 0x10},
+			{Name: "SHORT", Value: 0x10},
+			{Name: "NUM", Value: 0x1A},
+			{Name: "END", Value: 0x20},
+		},
+		Lines: []debuginfo.Line{{Addr: 0x00, Length: 3, Line: 2, Code: true}},
+		Data: []debuginfo.Data{
+			{Name: "TEXT", Addr: 0x10, Size: 10, Type: "BYTE"},
+			{Name: "NUM", Addr: 0x1A, Size: 3, Type: "WORD"},
+		},
+	})
+
+	checkRows(t, "LabelDump", m.LabelDump(0x00, 0x20), []string{
+		"Addr    Label      Type   Size  Contents",
+		"000000  MAIN       code      -  LDA #5",
+		"000010  SHORT                -  303132 (Dec: 3158322)",
+		"000010  TEXT       BYTE     10  [30 31 32 33 34 35 36 37] ...",
+		"00001A  NUM        WORD      3  000007 (Dec: 7)",
+	})
+}
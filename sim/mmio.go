@@ -0,0 +1,226 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Default addresses and size of the memory-mapped text screen and keyboard, as used by SicTools
+const (
+	ScreenAddr   = 0xB800
+	ScreenCols   = 80
+	ScreenRows   = 25
+	KeyboardAddr = 0xC000
+)
+
+// Region is a memory-mapped I/O region. Loads and stores to its addresses are passed to the
+// region instead of memory, with offsets relative to the start of the region.
+// Regions may be accessed from other goroutines while the machine is running.
+type Region interface {
+	Load(offset int) byte
+	Store(offset int, val byte)
+}
+
+// mappedRegion is a region mapped to the addresses addr..addr+size-1
+type mappedRegion struct {
+	addr, size int
+	region     Region
+}
+
+// MapRegion maps region to size bytes starting at addr, which must be inside memory and must not
+// overlap other regions
+func (m *Machine) MapRegion(addr, size int, region Region) error {
+	if size < 1 || !m.isAddr(addr) || !m.isAddr(addr+size-1) {
+		return fmt.Errorf("region 0x%06X-0x%06X is outside memory", addr, addr+size-1)
+	}
+
+	for _, r := range m.regions {
+		if addr < r.addr+r.size && r.addr < addr+size {
+			return fmt.Errorf("region 0x%06X-0x%06X overlaps region 0x%06X-0x%06X",
+				addr, addr+size-1, r.addr, r.addr+r.size-1)
+		}
+	}
+
+	m.regions = append(m.regions, mappedRegion{addr: addr, size: size, region: region})
+
+	if m.debug {
+		m.logger.Printf("Mapped %T to 0x%06X-0x%06X\n", region, addr, addr+size-1)
+	}
+
+	return nil
+}
+
+// UnmapRegion removes the region starting at addr
+func (m *Machine) UnmapRegion(addr int) error {
+	for i, r := range m.regions {
+		if r.addr == addr {
+			m.regions = append(m.regions[:i], m.regions[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no region starts at 0x%06X", addr)
+}
+
+// load returns the byte at a valid addr, from memory or from the region mapped to it
+func (m *Machine) load(addr int) byte {
+	for _, r := range m.regions {
+		if addr >= r.addr && addr < r.addr+r.size {
+			return r.region.Load(addr - r.addr)
+		}
+	}
+
+	return m.mem[addr]
+}
+
+// store sets the byte at a valid addr, in memory or in the region mapped to it
+func (m *Machine) store(addr int, val byte) {
+	for _, r := range m.regions {
+		if addr >= r.addr && addr < r.addr+r.size {
+			r.region.Store(addr-r.addr, val)
+			return
+		}
+	}
+
+	m.mem[addr] = val
+}
+
+// TextScreen is a memory-mapped text screen. Each byte holds the ASCII code of a character,
+// starting with the top left corner and continuing row by row.
+type TextScreen struct {
+	cols, rows int
+
+	mu      sync.Mutex
+	cells   []byte
+	changed bool
+}
+
+// NewTextScreen returns an empty screen with the given number of columns and rows
+func NewTextScreen(cols, rows int) *TextScreen {
+	return &TextScreen{cols: cols, rows: rows, cells: make([]byte, cols*rows)}
+}
+
+// Size returns the number of bytes the screen occupies in memory
+func (s *TextScreen) Size() int {
+	return s.cols * s.rows
+}
+
+func (s *TextScreen) Load(offset int) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cells[offset]
+}
+
+func (s *TextScreen) Store(offset int, val byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cells[offset] != val {
+		s.cells[offset] = val
+		s.changed = true
+	}
+}
+
+// Changed reports if the screen changed since the last call
+func (s *TextScreen) Changed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := s.changed
+	s.changed = false
+	return changed
+}
+
+// Text returns the screen's rows as lines of text. Zero bytes are shown as spaces and other
+// non-printable characters as dots, trailing spaces are removed.
+func (s *TextScreen) Text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sb strings.Builder
+
+	for row := 0; row < s.rows; row++ {
+		line := make([]byte, s.cols)
+
+		for col, val := range s.cells[row*s.cols : (row+1)*s.cols] {
+			switch {
+			case val == 0:
+				line[col] = ' '
+			case val < 0x20 || val >= 0x7F:
+				line[col] = '.'
+			default:
+				line[col] = val
+			}
+		}
+
+		sb.WriteString(strings.TrimRight(string(line), " "))
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// Keyboard is a memory-mapped keyboard register. It holds the ASCII code of the oldest key press
+// that wasn't acknowledged yet, or 0 if there is none. Programs acknowledge a key by storing any
+// value (usually 0) into the register.
+type Keyboard struct {
+	mu   sync.Mutex
+	keys []byte
+}
+
+// NewKeyboard returns a keyboard without key presses
+func NewKeyboard() *Keyboard {
+	return &Keyboard{}
+}
+
+// Press queues a key press
+func (k *Keyboard) Press(key byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = append(k.keys, key)
+}
+
+// Feed presses every byte read from r, until r returns an error or io.EOF
+func (k *Keyboard) Feed(r io.Reader) error {
+	buf := make([]byte, 64)
+
+	for {
+		n, err := r.Read(buf)
+
+		for _, key := range buf[:n] {
+			k.Press(key)
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (k *Keyboard) Load(int) byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if len(k.keys) == 0 {
+		return 0
+	}
+
+	return k.keys[0]
+}
+
+func (k *Keyboard) Store(int, byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if len(k.keys) > 0 {
+		k.keys = k.keys[1:]
+	}
+}
@@ -0,0 +1,109 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMapRegion checks that loads and stores inside a region go to the region instead of memory
+func TestMapRegion(t *testing.T) {
+	var m Machine
+	m.New()
+
+	screen := NewTextScreen(4, 2)
+
+	if err := m.MapRegion(0x1000, screen.Size(), screen); err != nil {
+		t.Fatal(err)
+	}
+
+	// The word straddles the start of the region
+	if err := m.SetWord(0x0FFF, 0x414243); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.mem[0x0FFF] != 0x41 || m.mem[0x1000] != 0 || m.mem[0x1001] != 0 {
+		t.Error("store inside the region changed memory")
+	}
+
+	if screen.Load(0) != 0x42 || screen.Load(1) != 0x43 {
+		t.Errorf("region holds %02X %02X, want 42 43", screen.Load(0), screen.Load(1))
+	}
+
+	if word, _ := m.Word(0x0FFF); word != 0x414243 {
+		t.Errorf("loaded %06X, want 414243", word)
+	}
+
+	for _, r := range [][2]int{{0x1007, 2}, {0x0FFF, 2}, {0x0FFFFF, 2}, {0x2000, 0}} {
+		if err := m.MapRegion(r[0], r[1], NewKeyboard()); err == nil {
+			t.Errorf("mapped a region of %d bytes at %06X", r[1], r[0])
+		}
+	}
+
+	if err := m.UnmapRegion(0x1001); err == nil {
+		t.Error("unmapped a region that doesn't start at the address")
+	}
+
+	if err := m.UnmapRegion(0x1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if val, _ := m.Byte(0x1000); val != 0 {
+		t.Errorf("unmapped region still loads %02X", val)
+	}
+}
+
+// TestTextScreen checks the screen's text and change tracking
+func TestTextScreen(t *testing.T) {
+	screen := NewTextScreen(4, 3)
+
+	if screen.Changed() {
+		t.Error("new screen changed")
+	}
+
+	for i, val := range []byte{'H', 'i', 0, 0x07, 0, 'x'} {
+		screen.Store(i, val)
+	}
+
+	if !screen.Changed() || screen.Changed() {
+		t.Error("Changed doesn't report a change exactly once")
+	}
+
+	screen.Store(0, 'H')
+
+	if screen.Changed() {
+		t.Error("storing the same character changed the screen")
+	}
+
+	if want := "Hi .\n x\n\n"; screen.Text() != want {
+		t.Errorf("text %q, want %q", screen.Text(), want)
+	}
+}
+
+// TestKeyboard checks that the register holds the oldest key until a store acknowledges it
+func TestKeyboard(t *testing.T) {
+	kb := NewKeyboard()
+
+	if kb.Load(0) != 0 {
+		t.Error("keyboard without key presses isn't 0")
+	}
+
+	if err := kb.Feed(strings.NewReader("ab")); err != nil {
+		t.Fatal(err)
+	}
+
+	kb.Press('c')
+
+	for _, key := range []byte("abc") {
+		if got := kb.Load(0); got != key {
+			t.Errorf("register holds %q, want %q", got, key)
+		}
+
+		kb.Store(0, 0)
+	}
+
+	kb.Store(0, 0)
+
+	if kb.Load(0) != 0 {
+		t.Error("acknowledged all keys, but the register isn't 0")
+	}
+}
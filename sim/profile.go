@@ -0,0 +1,93 @@
+package sim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile describes the machine model being simulated
+type Profile struct {
+	Name    string
+	MemSize int  // Size of memory in bytes
+	XE      bool // Enables the SIC/XE instructions, addressing modes and registers
+}
+
+// Predefined machine profiles
+var (
+	ProfileSIC = Profile{Name: "SIC", MemSize: 1 << 15, XE: false}
+	ProfileXE  = Profile{Name: "SIC/XE", MemSize: 1 << 20, XE: true}
+)
+
+// sicOpcodes holds the instructions of the original SIC machine
+var sicOpcodes = map[byte]bool{
+	ADD: true, AND: true, COMP: true, DIV: true, J: true, JEQ: true, JGT: true, JLT: true,
+	JSUB: true, LDA: true, LDCH: true, LDL: true, LDX: true, MUL: true, OR: true, RD: true,
+	RSUB: true, STA: true, STCH: true, STL: true, STSW: true, STX: true, SUB: true, TD: true,
+	TIX: true, WD: true,
+}
+
+// ParseProfile returns the predefined profile with the given name (sic or xe)
+func ParseProfile(name string) (Profile, error) {
+	switch strings.ToLower(name) {
+	case "sic":
+		return ProfileSIC, nil
+	case "xe", "sicxe", "sic/xe":
+		return ProfileXE, nil
+	}
+
+	return Profile{}, fmt.Errorf("unknown machine profile: %s", name)
+}
+
+// WithMemSize returns a copy of the profile with a custom memory size
+func (p Profile) WithMemSize(size int) Profile {
+	p.MemSize = size
+	return p
+}
+
+// maxMemSize returns the size of the profile's address space
+func (p Profile) maxMemSize() int {
+	if p.XE {
+		return ProfileXE.MemSize
+	}
+
+	return ProfileSIC.MemSize
+}
+
+// Profile returns the machine's profile
+func (m *Machine) Profile() Profile {
+	return m.profile
+}
+
+// SetProfile sets the machine's profile and replaces its memory with zeroed memory of the
+// profile's size, so it should be called before a program is loaded
+func (m *Machine) SetProfile(p Profile) error {
+	if p.MemSize < 3 || p.MemSize > p.maxMemSize() {
+		return fmt.Errorf("not a valid memory size for %s: %d bytes (max %d)", p.Name, p.MemSize, p.maxMemSize())
+	}
+
+	m.profile = p
+	m.mem = make([]byte, p.MemSize)
+	return nil
+}
+
+// MemSize returns the size of the machine's memory in bytes
+func (m *Machine) MemSize() int {
+	return len(m.mem)
+}
+
+// checkProfile returns an error if opcode is not available in the machine's profile
+func (m *Machine) checkProfile(opcode byte) error {
+	if m.profile.XE {
+		return nil
+	}
+
+	if opcode&0x03 != 0 {
+		return fmt.Errorf("format 3/4 instructions are not available on %s", m.profile.Name)
+	}
+
+	if !sicOpcodes[opcode] {
+		return fmt.Errorf("instruction 0x%02X is not available on %s", opcode, m.profile.Name)
+	}
+
+	return nil
+}
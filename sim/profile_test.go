@@ -0,0 +1,90 @@
+package sim
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestSICProfile checks that the SIC profile rejects XE-only instructions and formats
+func TestSICProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+		ok   bool
+	}{
+		{"SIC instruction", []byte{LDA, 0x00, 0x30}, true},
+		{"TIX", []byte{TIX, 0x00, 0x30}, true},
+		{"format 1", []byte{TIO}, false},
+		{"format 2", []byte{CLEAR, 0x00}, false},
+		{"format 3", []byte{LDA | 0x03, 0x00, 0x30}, false},
+		{"immediate", []byte{LDA | 0x01, 0x00, 0x30}, false},
+		{"XE instruction", []byte{LDB, 0x00, 0x30}, false},
+	}
+
+	for _, tt := range tests {
+		var m Machine
+		m.New()
+
+		if err := m.SetProfile(ProfileSIC); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, b := range tt.code {
+			m.SetByte(i, b)
+		}
+
+		err := m.Execute()
+
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+
+			continue
+		}
+
+		var fault *Fault
+		if !errors.As(err, &fault) || fault.Kind != FaultIllegalInstruction || m.PC() != 0 {
+			t.Errorf("%s: got %v at %06X, want an illegal instruction fault at 0", tt.name, err, m.PC())
+		}
+	}
+}
+
+// TestProfileMemory checks profile names and memory sizes
+func TestProfileMemory(t *testing.T) {
+	for name, want := range map[string]Profile{"sic": ProfileSIC, "XE": ProfileXE, "sic/xe": ProfileXE} {
+		if p, err := ParseProfile(name); err != nil || p != want {
+			t.Errorf("%s: got %v, %v, want %v", name, p, err, want)
+		}
+	}
+
+	if _, err := ParseProfile("sic/xe2"); err == nil {
+		t.Error("parsed an unknown profile")
+	}
+
+	var m Machine
+	m.New()
+
+	tests := []struct {
+		profile Profile
+		ok      bool
+	}{
+		{ProfileSIC, true},
+		{ProfileSIC.WithMemSize(1 << 16), false},
+		{ProfileXE.WithMemSize(1 << 16), true},
+		{ProfileXE.WithMemSize(1<<20 + 1), false},
+		{ProfileXE.WithMemSize(2), false},
+	}
+
+	for _, tt := range tests {
+		err := m.SetProfile(tt.profile)
+
+		if (err == nil) != tt.ok {
+			t.Errorf("%s with %d bytes: got %v", tt.profile.Name, tt.profile.MemSize, err)
+		}
+
+		if err == nil && m.MemSize() != tt.profile.MemSize {
+			t.Errorf("%s: memory is %d bytes, want %d", tt.profile.Name, m.MemSize(), tt.profile.MemSize)
+		}
+	}
+}
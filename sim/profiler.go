@@ -0,0 +1,279 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// maxCallDepth limits the tracked call stack, so programs that use JSUB without RSUB don't grow it
+// without bounds. Deeper calls are attributed to the deepest tracked subroutine.
+const maxCallDepth = 1024
+
+// ProfileCount is the number of executions and the cycles they used
+type ProfileCount struct {
+	Executions int
+	Cycles     int
+}
+
+// add counts an execution that used cycles
+func (c *ProfileCount) add(cycles int) {
+	c.Executions++
+	c.Cycles += cycles
+}
+
+// subroutine holds the profile of a subroutine, identified by its entry address
+type subroutine struct {
+	calls     int
+	self      int // Cycles used by the subroutine's own instructions
+	inclusive int // Cycles used by the subroutine and the subroutines it called
+}
+
+// Profiler counts executions and cycles per instruction address and per subroutine, tracking
+// subroutine calls with JSUB and returns with RSUB. Attach it to a machine with SetProfiler.
+type Profiler struct {
+	addrs       map[int]*ProfileCount
+	subroutines map[int]*subroutine
+	stacks      map[string]int // Cycles by folded call stack, which holds entry addresses
+	calls       []int          // Entry addresses of the called subroutines, the first is the program's entry
+	keys        []string       // Keys of stacks for each depth of calls
+	onStack     map[int]int    // Number of times each entry address is in calls
+	total       int            // Cycles used by all profiled instructions
+	depth       int            // Calls deeper than maxCallDepth
+}
+
+// NewProfiler returns an empty profiler
+func NewProfiler() *Profiler {
+	return &Profiler{
+		addrs:       make(map[int]*ProfileCount),
+		subroutines: make(map[int]*subroutine),
+		stacks:      make(map[string]int),
+		onStack:     make(map[int]int),
+	}
+}
+
+// Profiler returns the machine's profiler, or nil if profiling is disabled
+func (m *Machine) Profiler() *Profiler {
+	return m.profiler
+}
+
+// SetProfiler sets the profiler that counts executed instructions, nil disables profiling
+func (m *Machine) SetProfiler(p *Profiler) {
+	m.profiler = p
+}
+
+// record counts the instruction at addr, which used cycles and left PC at next
+func (p *Profiler) record(addr int, opcode byte, cycles, next int) {
+	if len(p.calls) == 0 {
+		p.calls = append(p.calls, addr)
+		p.keys = append(p.keys, fmt.Sprintf("%X", addr))
+		p.onStack[addr]++
+		p.subroutines[addr] = &subroutine{calls: 1}
+	}
+
+	count, ok := p.addrs[addr]
+	if !ok {
+		count = &ProfileCount{}
+		p.addrs[addr] = count
+	}
+
+	count.add(cycles)
+	p.total += cycles
+
+	p.subroutines[p.calls[len(p.calls)-1]].self += cycles
+	p.stacks[p.keys[len(p.keys)-1]] += cycles
+
+	// Recursive subroutines are counted once
+	for entry := range p.onStack {
+		p.subroutines[entry].inclusive += cycles
+	}
+
+	switch opcode & 0xFC {
+	case JSUB:
+		if len(p.calls) >= maxCallDepth {
+			p.depth++
+			break
+		}
+
+		sub, ok := p.subroutines[next]
+		if !ok {
+			sub = &subroutine{}
+			p.subroutines[next] = sub
+		}
+
+		sub.calls++
+		p.calls = append(p.calls, next)
+		p.keys = append(p.keys, fmt.Sprintf("%s;%X", p.keys[len(p.keys)-1], next))
+		p.onStack[next]++
+	case RSUB:
+		if p.depth > 0 {
+			p.depth--
+		} else if len(p.calls) > 1 {
+			entry := p.calls[len(p.calls)-1]
+			p.calls = p.calls[:len(p.calls)-1]
+			p.keys = p.keys[:len(p.keys)-1]
+
+			if p.onStack[entry]--; p.onStack[entry] == 0 {
+				delete(p.onStack, entry)
+			}
+		}
+	}
+}
+
+// Count returns the executions and cycles of the instruction at addr
+func (p *Profiler) Count(addr int) ProfileCount {
+	if count, ok := p.addrs[addr]; ok {
+		return *count
+	}
+
+	return ProfileCount{}
+}
+
+// Cycles returns the cycles used by all profiled instructions
+func (p *Profiler) Cycles() int {
+	return p.total
+}
+
+// percent returns cycles as a percentage of all profiled cycles
+func (p *Profiler) percent(cycles int) float64 {
+	if p.total == 0 {
+		return 0
+	}
+
+	return 100 * float64(cycles) / float64(p.total)
+}
+
+// WriteReport writes the hot spots of the program: the top instructions by cycles with their
+// disassembly, followed by the cycles per label and per subroutine. A top of 0 lists everything.
+func (p *Profiler) WriteReport(w io.Writer, m *Machine, top int) error {
+	labels := m.sortedSymbols()
+
+	addrs := make([]int, 0, len(p.addrs))
+	for addr := range p.addrs {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Slice(addrs, func(i, j int) bool {
+		ci, cj := p.addrs[addrs[i]], p.addrs[addrs[j]]
+		if ci.Cycles != cj.Cycles {
+			return ci.Cycles > cj.Cycles
+		}
+
+		return addrs[i] < addrs[j]
+	})
+
+	if top > 0 && len(addrs) > top {
+		addrs = addrs[:top]
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Hot spots (%d cycles in total):\n", p.total)
+	fmt.Fprintf(&sb, "  %-8s %-12s %10s %10s %7s  %s\n", "Address", "Label", "Count", "Cycles", "%", "Instruction")
+
+	for _, addr := range addrs {
+		count := p.addrs[addr]
+		inst, _, err := m.Disassemble(addr)
+		if err != nil {
+			inst = "?"
+		}
+
+		fmt.Fprintf(&sb, "  %06X   %-12s %10d %10d %6.2f%%  %s\n",
+			addr, labelOf(labels, addr), count.Executions, count.Cycles, p.percent(count.Cycles), inst)
+	}
+
+	if len(labels) > 0 {
+		byLabel := make(map[string]*ProfileCount)
+		var names []string
+
+		for addr, count := range p.addrs {
+			name := labelOf(labels, addr)
+			if name == "" {
+				name = "(none)"
+			}
+
+			if _, ok := byLabel[name]; !ok {
+				byLabel[name] = &ProfileCount{}
+				names = append(names, name)
+			}
+
+			byLabel[name].Executions += count.Executions
+			byLabel[name].Cycles += count.Cycles
+		}
+
+		sort.Slice(names, func(i, j int) bool {
+			ci, cj := byLabel[names[i]], byLabel[names[j]]
+			if ci.Cycles != cj.Cycles {
+				return ci.Cycles > cj.Cycles
+			}
+
+			return names[i] < names[j]
+		})
+
+		fmt.Fprintf(&sb, "\nLabels:\n")
+		fmt.Fprintf(&sb, "  %-12s %10s %10s %7s\n", "Label", "Count", "Cycles", "%")
+
+		for _, name := range names {
+			count := byLabel[name]
+			fmt.Fprintf(&sb, "  %-12s %10d %10d %6.2f%%\n", name, count.Executions, count.Cycles, p.percent(count.Cycles))
+		}
+	}
+
+	entries := make([]int, 0, len(p.subroutines))
+	for entry := range p.subroutines {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		si, sj := p.subroutines[entries[i]], p.subroutines[entries[j]]
+		if si.inclusive != sj.inclusive {
+			return si.inclusive > sj.inclusive
+		}
+
+		return entries[i] < entries[j]
+	})
+
+	fmt.Fprintf(&sb, "\nSubroutines:\n")
+	fmt.Fprintf(&sb, "  %-20s %8s %10s %7s %10s %7s\n", "Subroutine", "Calls", "Self", "%", "Inclusive", "%")
+
+	for _, entry := range entries {
+		sub := p.subroutines[entry]
+		fmt.Fprintf(&sb, "  %-20s %8d %10d %6.2f%% %10d %6.2f%%\n", m.addrName(entry), sub.calls,
+			sub.self, p.percent(sub.self), sub.inclusive, p.percent(sub.inclusive))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteFolded writes the cycles used in each call stack in the folded stack format accepted by
+// flame graph tools: one line per stack, with subroutines separated by semicolons, followed by
+// a space and the number of cycles. Subroutines are named by their label or entry address.
+func (p *Profiler) WriteFolded(w io.Writer, m *Machine) error {
+	keys := make([]string, 0, len(p.stacks))
+	for key := range p.stacks {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var sb strings.Builder
+
+	for _, key := range keys {
+		for i, entry := range strings.Split(key, ";") {
+			if i > 0 {
+				sb.WriteByte(';')
+			}
+
+			var addr int
+			fmt.Sscanf(entry, "%X", &addr)
+			sb.WriteString(m.addrName(addr))
+		}
+
+		fmt.Fprintf(&sb, " %d\n", p.stacks[key])
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,101 @@
+package sim
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erazemk/sicsim/asm"
+)
+
+// profiledLoop calls a subroutine 3 times, which spends most of the cycles in DIV
+const profiledLoop = `prof	START	0
+	LDA	#0
+loop	JSUB	body
+	ADD	#1
+	COMP	#3
+	JLT	loop
+halt	J	halt
+body	DIV	#1
+	RSUB
+	END	prof
+`
+
+// TestProfiler profiles an assembled loop and checks the counts, hot spots and folded stacks
+func TestProfiler(t *testing.T) {
+	dir := t.TempDir()
+	src, obj := filepath.Join(dir, "prof.asm"), filepath.Join(dir, "prof.obj")
+
+	if err := os.WriteFile(src, []byte(profiledLoop), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := asm.AssembleFile(src, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	var m Machine
+	m.New()
+
+	if err := m.ParseObjFile(obj); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProfiler()
+	m.SetProfiler(p)
+
+	if res := m.Run(context.Background(), Limits{Steps: 100}); res.Halt != HaltSelfJump {
+		t.Fatalf("program stopped: %s", res.Reason)
+	}
+
+	// DIV uses 13 cycles, the other instructions 3
+	counts := map[int]ProfileCount{
+		0x00: {1, 3},  // LDA #0
+		0x03: {3, 9},  // JSUB body
+		0x0F: {1, 3},  // J halt
+		0x12: {3, 39}, // DIV #1
+		0x15: {3, 9},  // RSUB
+	}
+
+	for addr, want := range counts {
+		if got := p.Count(addr); got != want {
+			t.Errorf("instruction at %06X: got %+v, want %+v", addr, got, want)
+		}
+	}
+
+	if p.Cycles() != 90 || m.Cycles() != 90 {
+		t.Errorf("profiled %d of %d cycles, want 90", p.Cycles(), m.Cycles())
+	}
+
+	var report strings.Builder
+
+	if err := p.WriteReport(&report, &m, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// The top instruction is DIV, followed by the instructions with the same cycles by address
+	lines := strings.Split(report.String(), "\n")
+	hot := []string{"000012   body", "000003   loop", "000006   loop"}
+
+	for i, prefix := range hot {
+		if !strings.HasPrefix(strings.TrimSpace(lines[2+i]), prefix) {
+			t.Errorf("hot spot %d is %q, want %q", i+1, lines[2+i], prefix)
+		}
+	}
+
+	if strings.Contains(report.String(), "000009") {
+		t.Error("report lists more than the top 3 instructions")
+	}
+
+	var folded strings.Builder
+
+	if err := p.WriteFolded(&folded, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "prof 42\nprof;body 48\n"; folded.String() != want {
+		t.Errorf("folded stacks %q, want %q", folded.String(), want)
+	}
+}
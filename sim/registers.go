@@ -1,21 +1,34 @@
 package sim
 
 import (
-	"encoding/binary"
 	"fmt"
+	"strings"
 )
 
 type registers struct {
-	a  int
-	x  int
-	l  int
-	b  int
-	s  int
-	t  int
-	f  int
-	pc int
-	sw int
-}
+	a  Word
+	x  Word
+	l  Word
+	b  Word
+	s  Word
+	t  Word
+	f  Word
+	pc Word
+	sw Word
+}
+
+// Register numbers
+const (
+	regA  = 0
+	regX  = 1
+	regL  = 2
+	regB  = 3
+	regS  = 4
+	regT  = 5
+	regF  = 6
+	regPC = 8
+	regSW = 9
+)
 
 // SW register values
 const (
@@ -24,61 +37,69 @@ const (
 	GT = 0x80
 )
 
-// Reg returns the value of register reg
+// RegisterNumber returns the number of the register with name (A, X, L, B, S, T, F, PC or SW),
+// ignoring case
+func RegisterNumber(name string) (int, bool) {
+	for no, reg := range registerNames {
+		if strings.EqualFold(reg, name) {
+			return no, true
+		}
+	}
+
+	return 0, false
+}
+
+// Reg returns the value of register reg. PC and SW are unsigned, the other registers are signed.
 func (m *Machine) Reg(reg int) (int, error) {
 	switch reg {
-	case 0:
-		return m.regs.a, nil
-	case 1:
-		return m.regs.x, nil
-	case 2:
-		return m.regs.l, nil
-	case 3:
-		return m.regs.b, nil
-	case 4:
-		return m.regs.s, nil
-	case 5:
-		return m.regs.t, nil
-	case 6:
-		return m.regs.f, nil
-	case 8:
-		return m.regs.pc, nil
-	case 9:
-		return m.regs.sw, nil
+	case regA:
+		return m.A(), nil
+	case regX:
+		return m.X(), nil
+	case regL:
+		return m.L(), nil
+	case regB:
+		return m.B(), nil
+	case regS:
+		return m.S(), nil
+	case regT:
+		return m.T(), nil
+	case regF:
+		return m.F(), nil
+	case regPC:
+		return m.PC(), nil
+	case regSW:
+		return m.SW(), nil
 	}
 
 	return -1, fmt.Errorf("not a valid register: %d", reg)
 }
 
-// SetReg sets the value of register reg
+// SetReg sets the value of register reg, wrapping val to 24 bits
 func (m *Machine) SetReg(reg int, val int) error {
 	if !isRegister(reg) {
 		return fmt.Errorf("not a valid register: %d", reg)
 	}
 
-	if !isWord(val) {
-		return fmt.Errorf("not a valid register value: %d", val)
-	}
-
 	switch reg {
-	case 0:
-		m.regs.a = val
-	case 1:
-		m.regs.x = val
-	case 2:
-		m.regs.l = val
-	case 3:
-		m.regs.b = val
-	case 4:
-		m.regs.s = val
-	case 5:
-		m.regs.t = val
-	case 6:
-		m.regs.f = val
-	case 8:
-		m.regs.pc = val
-	case 9:
-		m.regs.sw = val
+	case regA:
+		m.regs.a = NewWord(val)
+	case regX:
+		m.regs.x = NewWord(val)
+	case regL:
+		m.regs.l = NewWord(val)
+	case regB:
+		m.regs.b = NewWord(val)
+	case regS:
+		m.regs.s = NewWord(val)
+	case regT:
+		m.regs.t = NewWord(val)
+	case regF:
+		m.regs.f = NewWord(val)
+	case regPC:
+		m.regs.pc = NewWord(val)
+	case regSW:
+		m.regs.sw = NewWord(val)
 	}
 
 	return nil
@@ -86,139 +107,117 @@ func (m *Machine) SetReg(reg int, val int) error {
 
 // A returns the value of the A register
 func (m *Machine) A() int {
-	return m.regs.a
+	return m.regs.a.Int()
 }
 
 // ALow returns the lowest byte of the A register
 func (m *Machine) ALow() byte {
-	bytes := make([]byte, 4)
-	num := m.regs.a & 0xFF
-	binary.BigEndian.PutUint32(bytes, uint32(num))
-	return bytes[3]
+	return byte(m.regs.a)
 }
 
 // X returns the value of the X register
 func (m *Machine) X() int {
-	return m.regs.x
+	return m.regs.x.Int()
 }
 
 // L returns the value of the L register
 func (m *Machine) L() int {
-	return m.regs.l
+	return m.regs.l.Int()
 }
 
 // B returns the value of the B register
 func (m *Machine) B() int {
-	return m.regs.b
+	return m.regs.b.Int()
 }
 
 // S returns the value of the S register
 func (m *Machine) S() int {
-	return m.regs.s
+	return m.regs.s.Int()
 }
 
 // T returns the value of the T register
 func (m *Machine) T() int {
-	return m.regs.t
+	return m.regs.t.Int()
 }
 
 // F returns the value of the F register
 func (m *Machine) F() int {
-	return m.regs.f
+	return m.regs.f.Int()
 }
 
-// PC returns the value of the PC register
+// PC returns the (unsigned) value of the PC register
 func (m *Machine) PC() int {
-	return m.regs.pc
+	return m.regs.pc.Uint()
 }
 
-// SW returns the value of the SW register
+// SW returns the (unsigned) value of the SW register
 func (m *Machine) SW() int {
-	return m.regs.sw
+	return m.regs.sw.Uint()
 }
 
 // SetA sets the value of the A register
 func (m *Machine) SetA(val int) {
-	if isWord(val) {
-		m.regs.a = val
-	}
+	m.regs.a = NewWord(val)
 }
 
-// SetALow sets the value of lowest byte of the A register
+// SetALow sets the value of lowest byte of the A register, leaving the other bytes untouched
 func (m *Machine) SetALow(val byte) {
-	bytes := make([]byte, 4)
-	bytes[3] = val
-	m.regs.a = int(binary.BigEndian.Uint32(bytes))
+	m.regs.a = NewWord(m.regs.a.Uint()&^0xFF | int(val))
 }
 
 // SetX sets the value of the X register
 func (m *Machine) SetX(val int) {
-	if isWord(val) {
-		m.regs.x = val
-	}
+	m.regs.x = NewWord(val)
 }
 
 // SetL sets the value of the L register
 func (m *Machine) SetL(val int) {
-	if isWord(val) {
-		m.regs.l = val
-	}
+	m.regs.l = NewWord(val)
 }
 
 // SetB sets the value of the B register
 func (m *Machine) SetB(val int) {
-	if isWord(val) {
-		m.regs.b = val
-	}
+	m.regs.b = NewWord(val)
 }
 
 // SetS sets the value of the S register
 func (m *Machine) SetS(val int) {
-	if isWord(val) {
-		m.regs.s = val
-	}
+	m.regs.s = NewWord(val)
 }
 
 // SetT sets the value of the T register
 func (m *Machine) SetT(val int) {
-	if isWord(val) {
-		m.regs.t = val
-	}
+	m.regs.t = NewWord(val)
 }
 
 // SetF sets the value of the F register
 func (m *Machine) SetF(val int) {
-	if isWord(val) {
-		m.regs.f = val
-	}
+	m.regs.f = NewWord(val)
 }
 
 // SetPC sets the value of the PC register
 func (m *Machine) SetPC(val int) {
-	if isWord(val) {
-		m.regs.pc = val
-	}
+	m.regs.pc = NewWord(val)
 }
 
 // SetSW sets the value of the SW register
 func (m *Machine) SetSW(val int) {
-	if isWord(val) {
-		m.regs.sw = val
-	}
+	m.regs.sw = NewWord(val)
 }
 
 // Print outputs the machine's register state
 func (m *Machine) Regs() string {
 	return fmt.Sprintf(
-		"A:  %06[1]X (Dec: %[1]d)\n"+
-			"X:  %06[2]X (Dec: %[2]d)\n"+
-			"L:  %06[3]X (Dec: %[3]d)\n"+
-			"B:  %06[4]X (Dec: %[4]d)\n"+
-			"S:  %06[5]X (Dec: %[5]d)\n"+
-			"T:  %06[6]X (Dec: %[6]d)\n"+
-			"F:  %06[7]X (Dec: %[7]d)\n"+
-			"PC: %06[8]X (Dec: %[8]d)\n"+
-			"SW: %06[9]X (Dec: %[9]d)",
-		m.regs.a, m.regs.x, m.regs.l, m.regs.b, m.regs.s, m.regs.t, m.regs.f,
-		m.regs.pc, m.regs.sw)
+		"A:  %06X (Dec: %d)\n"+
+			"X:  %06X (Dec: %d)\n"+
+			"L:  %06X (Dec: %d)\n"+
+			"B:  %06X (Dec: %d)\n"+
+			"S:  %06X (Dec: %d)\n"+
+			"T:  %06X (Dec: %d)\n"+
+			"F:  %06X (Dec: %d)\n"+
+			"PC: %06X (Dec: %d)\n"+
+			"SW: %06X (Dec: %d)",
+		m.regs.a.Uint(), m.A(), m.regs.x.Uint(), m.X(), m.regs.l.Uint(), m.L(),
+		m.regs.b.Uint(), m.B(), m.regs.s.Uint(), m.S(), m.regs.t.Uint(), m.T(),
+		m.regs.f.Uint(), m.F(), m.PC(), m.PC(), m.SW(), m.SW())
 }
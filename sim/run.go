@@ -0,0 +1,174 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// StopReason describes why Run returned
+type StopReason int
+
+const (
+	StopHalt       StopReason = iota // The machine halted, see Result.Halt
+	StopBreakpoint                   // PC reached a breakpoint
+	StopFault                        // An instruction failed to execute, see Result.Fault
+	StopStepLimit                    // The step budget was used up
+	StopCancelled                    // The context was cancelled or Stop was called
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopHalt:
+		return "halted"
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopFault:
+		return "fault"
+	case StopStepLimit:
+		return "step limit reached"
+	case StopCancelled:
+		return "cancelled"
+	}
+
+	return fmt.Sprintf("unknown stop reason (%d)", int(r))
+}
+
+// Limits bounds the execution of a single call to Run
+type Limits struct {
+	Steps int // Maximum number of instructions to execute (0 means no limit)
+}
+
+// Result describes how a call to Run ended
+type Result struct {
+	Reason       StopReason
+	Halt         HaltReason    // Why the machine halted, if Reason is StopHalt
+	Fault        *Fault        // The fault, if Reason is StopFault
+	PC           int           // Value of PC when Run returned
+	Instructions int           // Number of instructions executed during the run
+	Cycles       int           // Number of machine cycles used during the run
+	Elapsed      time.Duration // Wall-clock duration of the run
+}
+
+// Err returns the fault as an error, or nil if the run did not end with a fault
+func (r Result) Err() error {
+	if r.Fault == nil {
+		return nil
+	}
+
+	return r.Fault
+}
+
+// InstructionsPerSecond returns the execution rate of the run
+func (r Result) InstructionsPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(r.Instructions) / r.Elapsed.Seconds()
+}
+
+// AddBreakpoint makes Run stop before executing the instruction at addr
+func (m *Machine) AddBreakpoint(addr int) error {
+	if !m.isAddr(addr) {
+		return fmt.Errorf("not a valid address: %d", addr)
+	}
+
+	m.breakpoints[addr] = true
+	return nil
+}
+
+// RemoveBreakpoint removes the breakpoint at addr
+func (m *Machine) RemoveBreakpoint(addr int) {
+	delete(m.breakpoints, addr)
+}
+
+// Breakpoints returns the sorted addresses of all breakpoints
+func (m *Machine) Breakpoints() []int {
+	addrs := make([]int, 0, len(m.breakpoints))
+
+	for addr := range m.breakpoints {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Ints(addrs)
+	return addrs
+}
+
+// Run executes instructions until the machine halts, faults, reaches a breakpoint, uses up the
+// step budget or ctx is cancelled. The instruction at PC is always executed, even if it has a
+// breakpoint, so that a run can be resumed from a breakpoint. A Stop made before Run is called
+// stops the run before its first instruction, and is consumed once Run returns. A machine that
+// halted is resumed first, unless the halt was final (see HaltReason.Final).
+func (m *Machine) Run(ctx context.Context, limits Limits) Result {
+	atomic.StoreInt32(&m.running, 1)
+	m.Resume()
+
+	defer func() {
+		atomic.StoreInt32(&m.stopped, 0)
+		atomic.StoreInt32(&m.running, 0)
+	}()
+
+	start := time.Now()
+	startInstructions, startCycles := m.instructions, m.cycles
+	throttle := m.newThrottle(start)
+
+	res := m.run(ctx, limits, throttle)
+	res.PC = m.PC()
+	res.Instructions = m.instructions - startInstructions
+	res.Cycles = m.cycles - startCycles
+	res.Elapsed = time.Since(start)
+
+	m.ips = res.InstructionsPerSecond()
+	return res
+}
+
+// run is the execution loop of Run
+func (m *Machine) run(ctx context.Context, limits Limits, throttle *throttle) Result {
+	for steps := 0; ; steps++ {
+		if m.halted {
+			return Result{Reason: StopHalt, Halt: m.haltReason}
+		}
+
+		if limits.Steps > 0 && steps >= limits.Steps {
+			return Result{Reason: StopStepLimit}
+		}
+
+		if steps > 0 && m.breakpoints[m.PC()] {
+			return Result{Reason: StopBreakpoint}
+		}
+
+		if (throttle != nil || steps%checkInterval == 0) && m.cancelled(ctx) {
+			return Result{Reason: StopCancelled}
+		}
+
+		if err := m.Execute(); err != nil {
+			fault, ok := err.(*Fault)
+			if !ok {
+				fault = m.newFault(FaultIllegalInstruction, err)
+			}
+
+			return Result{Reason: StopFault, Fault: fault}
+		}
+
+		if throttle != nil {
+			throttle.wait(ctx)
+		}
+	}
+}
+
+// cancelled returns true if ctx is done or Stop was called
+func (m *Machine) cancelled(ctx context.Context) bool {
+	if atomic.LoadInt32(&m.stopped) == 1 {
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
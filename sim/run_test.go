@@ -0,0 +1,44 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestStop checks that Stop stops a running machine or the next run, and only that run
+func TestStop(t *testing.T) {
+	var m Machine
+	m.New()
+
+	// Memory is zeroed, so the machine executes LDA 0 forever
+	m.Stop()
+
+	if res := m.Run(context.Background(), Limits{Steps: 5}); res.Reason != StopCancelled || res.Instructions != 0 {
+		t.Errorf("run after Stop: got %s after %d instructions, want %s", res.Reason, res.Instructions, StopCancelled)
+	}
+
+	if res := m.Run(context.Background(), Limits{Steps: 5}); res.Reason != StopStepLimit || res.Instructions != 5 {
+		t.Errorf("second run: got %s after %d instructions, want %s", res.Reason, res.Instructions, StopStepLimit)
+	}
+
+	go func() {
+		for !m.IsRunning() {
+			time.Sleep(time.Millisecond)
+		}
+
+		m.Stop()
+	}()
+
+	done := make(chan Result)
+	go func() { done <- m.Start() }()
+
+	select {
+	case res := <-done:
+		if res.Reason != StopCancelled {
+			t.Errorf("stopped run: got %s, want %s", res.Reason, StopCancelled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't stop the running machine")
+	}
+}
@@ -0,0 +1,227 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// SocketDevice is a device connected to a TCP or Unix domain socket, either as a client or as
+// a listener that accepts a single peer. Bytes written with WD are sent to the peer and received
+// bytes are buffered for RD. TD reports if the device is ready for the direction it was last
+// used in: after RD it is ready while received bytes are waiting, otherwise while it can send.
+type SocketDevice struct {
+	listener  net.Listener
+	conn      net.Conn
+	connected chan struct{} // Closed once conn is set
+	done      chan struct{} // Closed once err is set
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []byte // Received bytes that haven't been read yet
+	err     error  // Set once the connection is closed or failed
+	reading bool   // The device was last used by RD
+}
+
+// DialSocketDevice connects to a listening socket, network is tcp or unix
+func DialSocketDevice(network, address string) (*SocketDevice, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect socket device: %w", err)
+	}
+
+	d := newSocketDevice()
+	d.connect(conn)
+	return d, nil
+}
+
+// ListenSocketDevice listens on a socket and accepts the first peer in the background, network is
+// tcp or unix. Until a peer connects the device isn't ready and writes block.
+func ListenSocketDevice(network, address string) (*SocketDevice, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket device: %w", err)
+	}
+
+	d := newSocketDevice()
+	d.listener = listener
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			d.fail(err)
+			return
+		}
+
+		d.connect(conn)
+	}()
+
+	return d, nil
+}
+
+func newSocketDevice() *SocketDevice {
+	d := &SocketDevice{connected: make(chan struct{}), done: make(chan struct{})}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// Addr returns the address the device listens on, or nil if it is a client
+func (d *SocketDevice) Addr() net.Addr {
+	if d.listener == nil {
+		return nil
+	}
+
+	return d.listener.Addr()
+}
+
+// connect starts receiving bytes from conn
+func (d *SocketDevice) connect(conn net.Conn) {
+	d.conn = conn
+	close(d.connected)
+
+	go func() {
+		buf := make([]byte, 4096)
+
+		for {
+			n, err := conn.Read(buf)
+
+			d.mu.Lock()
+			d.buf = append(d.buf, buf[:n]...)
+			d.cond.Broadcast()
+			d.mu.Unlock()
+
+			if err != nil {
+				d.fail(err)
+				return
+			}
+		}
+	}()
+}
+
+// fail records err as the reason no more bytes will be received and wakes up waiting reads and writes
+func (d *SocketDevice) fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err == nil {
+		d.err = err
+		close(d.done)
+	}
+
+	d.cond.Broadcast()
+}
+
+// Test reports if the device is ready for RD after it was last read from, and for WD otherwise
+func (d *SocketDevice) Test() bool {
+	d.mu.Lock()
+	reading := d.reading
+	d.mu.Unlock()
+
+	if reading {
+		return d.Readable()
+	}
+
+	return d.Writable()
+}
+
+// Readable reports if received bytes are waiting to be read
+func (d *SocketDevice) Readable() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.buf) > 0
+}
+
+// Writable reports if a peer is connected and the connection didn't fail. The peer closing its
+// side of the connection doesn't prevent sending to it.
+func (d *SocketDevice) Writable() bool {
+	select {
+	case <-d.connected:
+	default:
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.err == nil || d.err == io.EOF
+}
+
+// EOF reports if the peer closed the connection and all received bytes were read
+func (d *SocketDevice) EOF() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.buf) == 0 && d.err == io.EOF
+}
+
+// Read returns the next received byte, waiting for one if none are buffered.
+// It returns io.EOF once the peer closed the connection and all bytes were read.
+func (d *SocketDevice) Read() (byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.reading = true
+
+	for len(d.buf) == 0 && d.err == nil {
+		d.cond.Wait()
+	}
+
+	if len(d.buf) == 0 {
+		if d.err == io.EOF {
+			return 0, io.EOF
+		}
+
+		return 0, fmt.Errorf("socket device failed: %w", d.err)
+	}
+
+	val := d.buf[0]
+	d.buf = d.buf[1:]
+	return val, nil
+}
+
+// Write sends val to the peer, waiting for a peer to connect if the device is a listener.
+// It fails if the listener is closed or fails before a peer connects.
+func (d *SocketDevice) Write(val byte) error {
+	d.mu.Lock()
+	d.reading = false
+	d.mu.Unlock()
+
+	select {
+	case <-d.connected:
+	case <-d.done:
+		// A peer may have connected and closed its side of the connection already
+		select {
+		case <-d.connected:
+		default:
+			d.mu.Lock()
+			err := d.err
+			d.mu.Unlock()
+
+			return fmt.Errorf("socket device failed: %w", err)
+		}
+	}
+
+	_, err := d.conn.Write([]byte{val})
+	return err
+}
+
+// Close closes the connection and the listener
+func (d *SocketDevice) Close() error {
+	var err error
+
+	if d.listener != nil {
+		err = d.listener.Close()
+	}
+
+	select {
+	case <-d.connected:
+		if cerr := d.conn.Close(); err == nil {
+			err = cerr
+		}
+	default:
+	}
+
+	return err
+}
@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestSocketExchange connects a client to a listener and sends bytes both ways
+func TestSocketExchange(t *testing.T) {
+	server, err := ListenSocketDevice("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer server.Close()
+
+	if server.Test() {
+		t.Error("listener is ready before a peer connected")
+	}
+
+	client, err := DialSocketDevice("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the listener to accept", server.Writable)
+
+	for _, b := range []byte("hi") {
+		if err := client.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, "received bytes", server.Readable)
+
+	for _, want := range []byte("hi") {
+		if got, err := server.Read(); err != nil || got != want {
+			t.Fatalf("got %q, %v, want %q", got, err, want)
+		}
+	}
+
+	if server.Test() {
+		t.Error("listener is ready for RD without received bytes")
+	}
+
+	if err := server.Write('!'); err != nil {
+		t.Fatal(err)
+	}
+
+	if !server.Test() {
+		t.Error("connected listener isn't ready for WD")
+	}
+
+	if got, err := client.Read(); err != nil || got != '!' {
+		t.Fatalf("got %q, %v, want '!'", got, err)
+	}
+
+	client.Close()
+	waitFor(t, "the end of input", server.EOF)
+
+	if _, err := server.Read(); err != io.EOF {
+		t.Errorf("read after the peer closed: got %v, want io.EOF", err)
+	}
+}
+
+// TestSocketWriteWithoutPeer checks that writing to a listener fails instead of waiting forever
+// once it is closed without a peer
+func TestSocketWriteWithoutPeer(t *testing.T) {
+	d, err := ListenSocketDevice("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error)
+	go func() { errs <- d.Write('x') }()
+
+	time.Sleep(10 * time.Millisecond)
+	d.Close()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("write without a peer succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write without a peer didn't return after Close")
+	}
+
+	if d.Test() {
+		t.Error("closed listener is ready")
+	}
+}
@@ -1,51 +1,98 @@
 package sim
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"sync/atomic"
 	"time"
 )
 
-// Start starts executing commands from memory
-func (m *Machine) Start() {
-	m.ticker = time.NewTicker(m.tick) // Always reset the ticker
-
-	// go func() {
-	for range m.ticker.C {
-		if !m.Halted() {
-			m.Execute()
-		} else {
-			m.Stop()
-
-			if !m.interactive {
-				fmt.Printf("\n-- Done (executed all instructions) --\n")
-				//fmt.Print("> ")
-				return
-			} else {
-				os.Exit(0)
-			}
-		}
+// batchInterval is how often a throttled machine checks that it keeps its target frequency
+const batchInterval = 10 * time.Millisecond
+
+// checkInterval is how many instructions an unthrottled machine executes between checks for cancellation
+const checkInterval = 1024
+
+// throttle keeps a machine at its target frequency by sleeping after each batch of instructions
+type throttle struct {
+	start    time.Time
+	speed    int
+	batch    int
+	executed int
+}
+
+// newThrottle returns a throttle for the machine's speed, or nil if the machine is unthrottled
+func (m *Machine) newThrottle(start time.Time) *throttle {
+	if m.speed == 0 {
+		return nil
+	}
+
+	batch := int(int64(m.speed) * int64(batchInterval) / int64(time.Second))
+	if batch < 1 {
+		batch = 1
 	}
-	// }()
+
+	return &throttle{start: start, speed: m.speed, batch: batch}
 }
 
-// Stop stops executing commands and stops the machine's ticker
+// wait counts an executed instruction and, at the end of a batch, sleeps until the time the
+// batch should have taken at the target frequency has passed
+func (t *throttle) wait(ctx context.Context) {
+	t.executed++
+
+	if t.executed%t.batch != 0 {
+		return
+	}
+
+	target := time.Duration(int64(t.executed) * int64(time.Second) / int64(t.speed))
+	ahead := target - time.Since(t.start)
+
+	if ahead <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(ahead)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// Start executes instructions until the machine halts, faults or is stopped.
+// It is a shorthand for Run without a context or limits.
+func (m *Machine) Start() Result {
+	return m.Run(context.Background(), Limits{})
+}
+
+// Stop stops a running machine, or its next run if it isn't running. It is safe to call from
+// another goroutine.
 func (m *Machine) Stop() {
-	m.ticker.Stop()
-	m.ticker = nil
+	atomic.StoreInt32(&m.stopped, 1)
 }
 
-// IsRunning returns the status of the machine's ticker
+// IsRunning returns true while the machine is executing instructions in Run
 func (m *Machine) IsRunning() bool {
-	return m.ticker != nil
+	return atomic.LoadInt32(&m.running) == 1
+}
+
+// Speed returns the target clock frequency in Hz, 0 means unthrottled
+func (m *Machine) Speed() int {
+	return m.speed
 }
 
-// Speed returns the current ticker speed
-func (m *Machine) Speed() string {
-	return m.tick.String()
+// SetSpeed sets the target clock frequency in Hz (instructions per second), 0 means unthrottled
+func (m *Machine) SetSpeed(hz int) error {
+	if hz < 0 {
+		return fmt.Errorf("not a valid frequency: %d Hz", hz)
+	}
+
+	m.speed = hz
+	return nil
 }
 
-func (m *Machine) SetSpeed(kHz int) {
-	// 1 kHz == 1 ms
-	m.tick = time.Duration(kHz * int(time.Millisecond))
+// InstructionsPerSecond returns the execution rate measured during the last run
+func (m *Machine) InstructionsPerSecond() float64 {
+	return m.ips
 }
@@ -0,0 +1,94 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestThrottleBatch checks the batch size for the target frequency
+func TestThrottleBatch(t *testing.T) {
+	tests := []struct {
+		speed int
+		batch int // 0 if the machine isn't throttled
+	}{
+		{0, 0},
+		{1, 1},
+		{50, 1},
+		{100, 1},
+		{1000, 10},
+		{1000000, 10000},
+	}
+
+	for _, tt := range tests {
+		var m Machine
+		m.New()
+
+		if err := m.SetSpeed(tt.speed); err != nil {
+			t.Fatal(err)
+		}
+
+		th := m.newThrottle(time.Now())
+
+		if tt.batch == 0 {
+			if th != nil {
+				t.Errorf("%d Hz: throttled", tt.speed)
+			}
+
+			continue
+		}
+
+		if th == nil || th.batch != tt.batch {
+			t.Errorf("%d Hz: got %+v, want a batch of %d", tt.speed, th, tt.batch)
+		}
+	}
+
+	var m Machine
+	m.New()
+
+	if err := m.SetSpeed(-1); err == nil {
+		t.Error("set a negative frequency")
+	}
+}
+
+// TestThrottleWait checks that the throttle only sleeps at the end of a batch, until the batch's
+// time at the target frequency has passed
+func TestThrottleWait(t *testing.T) {
+	start := time.Now()
+	th := &throttle{start: start, speed: 1000, batch: 10}
+
+	for i := 0; i < 9; i++ {
+		th.wait(context.Background())
+	}
+
+	if elapsed := time.Since(start); elapsed >= 9*time.Millisecond {
+		t.Errorf("slept %v within a batch", elapsed)
+	}
+
+	th.wait(context.Background())
+
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("batch of 10 instructions at 1 kHz took %v, want at least 10ms", elapsed)
+	}
+
+	// A throttle that fell behind doesn't sleep
+	behind := &throttle{start: start.Add(-time.Second), speed: 1000, batch: 1}
+	before := time.Now()
+	behind.wait(context.Background())
+
+	if elapsed := time.Since(before); elapsed >= 5*time.Millisecond {
+		t.Errorf("throttle behind its target slept %v", elapsed)
+	}
+
+	// Cancelling the context stops the sleep
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	slow := &throttle{start: time.Now(), speed: 1, batch: 1}
+	before = time.Now()
+	slow.wait(ctx)
+
+	if elapsed := time.Since(before); elapsed >= 500*time.Millisecond {
+		t.Errorf("cancelled wait slept %v", elapsed)
+	}
+}
@@ -0,0 +1,91 @@
+package sim
+
+// Word is a 24-bit two's complement SIC word. Its value is always kept sign-extended,
+// so that Go's integer operations give the right results before wrapping.
+type Word int32
+
+// Limits of signed 24-bit words
+const (
+	WordMin = -0x800000
+	WordMax = 0x7FFFFF
+)
+
+// NewWord wraps val to 24 bits, so both signed and unsigned representations are accepted
+func NewWord(val int) Word {
+	val &= 0xFFFFFF
+
+	if val > WordMax {
+		val -= 0x1000000
+	}
+
+	return Word(val)
+}
+
+// WordFromBytes returns the word with the big-endian bytes b
+func WordFromBytes(b0, b1, b2 byte) Word {
+	return NewWord(int(b0)<<16 | int(b1)<<8 | int(b2))
+}
+
+// Int returns the signed value of w
+func (w Word) Int() int {
+	return int(w)
+}
+
+// Uint returns the unsigned value of w
+func (w Word) Uint() int {
+	return int(w) & 0xFFFFFF
+}
+
+// Bytes returns the big-endian bytes of w
+func (w Word) Bytes() [3]byte {
+	u := w.Uint()
+	return [3]byte{byte(u >> 16), byte(u >> 8), byte(u)}
+}
+
+// wrap converts the exact result of an operation to a word and reports if it overflowed
+func wrap(val int64) (Word, bool) {
+	return NewWord(int(val)), val < WordMin || val > WordMax
+}
+
+// Add returns w + v and whether the addition overflowed
+func (w Word) Add(v Word) (Word, bool) {
+	return wrap(int64(w) + int64(v))
+}
+
+// Sub returns w - v and whether the subtraction overflowed
+func (w Word) Sub(v Word) (Word, bool) {
+	return wrap(int64(w) - int64(v))
+}
+
+// Mul returns w * v and whether the multiplication overflowed
+func (w Word) Mul(v Word) (Word, bool) {
+	return wrap(int64(w) * int64(v))
+}
+
+// Div returns w / v, truncated towards zero, and whether the division overflowed. v must not be 0.
+func (w Word) Div(v Word) (Word, bool) {
+	return wrap(int64(w) / int64(v))
+}
+
+// ShiftLeft returns w shifted left circularly by n bits, so the bits shifted out of the left
+// end enter at the right
+func (w Word) ShiftLeft(n int) Word {
+	u, s := w.Uint(), uint(n%24)
+	return NewWord(u<<s | u>>(24-s))
+}
+
+// ShiftRight returns w shifted right by n bits, filling the vacated bits with the sign bit
+func (w Word) ShiftRight(n int) Word {
+	return Word(int32(w) >> uint(n))
+}
+
+// Cmp compares w and v as signed values, returning -1, 0 or 1
+func (w Word) Cmp(v Word) int {
+	if w < v {
+		return -1
+	} else if w > v {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,115 @@
+package sim
+
+import "testing"
+
+// TestWordArith checks wrap-around and overflow reporting of word arithmetic
+func TestWordArith(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       func(Word, Word) (Word, bool)
+		a, b     int
+		want     int
+		overflow bool
+	}{
+		{"add", Word.Add, 2, 3, 5, false},
+		{"add negative", Word.Add, -2, -3, -5, false},
+		{"add overflow", Word.Add, WordMax, 1, WordMin, true},
+		{"add underflow", Word.Add, WordMin, -1, WordMax, true},
+		{"sub", Word.Sub, 2, 3, -1, false},
+		{"sub overflow", Word.Sub, WordMin, 1, WordMax, true},
+		{"sub from max", Word.Sub, WordMax, -1, WordMin, true},
+		{"mul", Word.Mul, -4, 5, -20, false},
+		{"mul overflow", Word.Mul, 0x1000, 0x1000, 0, true},
+		{"mul wraps", Word.Mul, 0x400000, 3, -0x400000, true},
+		{"div", Word.Div, 7, 2, 3, false},
+		{"div truncates negative", Word.Div, -7, 2, -3, false},
+		{"div negative divisor", Word.Div, 7, -2, -3, false},
+		{"div overflow", Word.Div, WordMin, -1, WordMin, true},
+	}
+
+	for _, tt := range tests {
+		got, overflow := tt.op(NewWord(tt.a), NewWord(tt.b))
+		if got.Int() != tt.want || overflow != tt.overflow {
+			t.Errorf("%s: got %d, %v, want %d, %v", tt.name, got.Int(), overflow, tt.want, tt.overflow)
+		}
+	}
+}
+
+// TestNewWord checks that signed and unsigned values are wrapped and sign-extended
+func TestNewWord(t *testing.T) {
+	tests := []struct {
+		val, signed, unsigned int
+	}{
+		{0, 0, 0},
+		{WordMax, WordMax, 0x7FFFFF},
+		{0x800000, WordMin, 0x800000},
+		{0xFFFFFF, -1, 0xFFFFFF},
+		{-1, -1, 0xFFFFFF},
+		{0x1000005, 5, 5},
+		{-0x800001, WordMax, 0x7FFFFF},
+	}
+
+	for _, tt := range tests {
+		w := NewWord(tt.val)
+		if w.Int() != tt.signed || w.Uint() != tt.unsigned {
+			t.Errorf("NewWord(%#x) = %d (%#x), want %d (%#x)", tt.val, w.Int(), w.Uint(), tt.signed, tt.unsigned)
+		}
+	}
+
+	if w := WordFromBytes(0xFF, 0xFF, 0xFE); w.Int() != -2 || w.Bytes() != [3]byte{0xFF, 0xFF, 0xFE} {
+		t.Errorf("WordFromBytes(FF FF FE) = %d, %X", w.Int(), w.Bytes())
+	}
+}
+
+// TestWordShift checks that left shifts are circular and right shifts keep the sign
+func TestWordShift(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Word
+		want int
+	}{
+		{"left", NewWord(0x000001).ShiftLeft(4), 0x000010},
+		{"left circular", NewWord(0x800001).ShiftLeft(1), 0x000003},
+		{"left by a byte", NewWord(0x123456).ShiftLeft(8), 0x345612},
+		{"left by 0", NewWord(0xABCDEF).ShiftLeft(0), 0xABCDEF},
+		{"left by 24", NewWord(0xABCDEF).ShiftLeft(24), 0xABCDEF},
+		{"right positive", NewWord(0x400000).ShiftRight(4), 0x040000},
+		{"right negative", NewWord(0x800000).ShiftRight(4), 0xF80000},
+		{"right to -1", NewWord(-1).ShiftRight(15), 0xFFFFFF},
+	}
+
+	for _, tt := range tests {
+		if tt.got.Uint() != tt.want {
+			t.Errorf("%s: got %06X, want %06X", tt.name, tt.got.Uint(), tt.want)
+		}
+	}
+}
+
+// TestTIXOverflow checks that TIX and TIXR fault on overflow of X only if overflow trapping is enabled
+func TestTIXOverflow(t *testing.T) {
+	for _, code := range [][]byte{{TIXR, 0x50}, {TIX, 0x00, 0x00}} {
+		for _, trap := range []bool{false, true} {
+			var m Machine
+			m.New()
+			m.SetOverflowTrap(trap)
+			m.SetX(WordMax)
+
+			for i, b := range code {
+				m.SetByte(i, b)
+			}
+
+			err := m.Execute()
+
+			switch {
+			case trap && err == nil:
+				t.Errorf("% X: overflow of X didn't fault", code)
+			case trap && m.X() != WordMax:
+				t.Errorf("% X: faulting instruction changed X to %d", code, m.X())
+			case !trap && err != nil:
+				t.Errorf("% X: %v", code, err)
+			case !trap && m.X() != WordMin:
+				t.Errorf("% X: X is %d, want %d", code, m.X(), WordMin)
+			}
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package simtest helps testing SIC/XE programs from Go tests. It assembles or loads a program
+// on a new machine, connects its devices to in-memory buffers, runs it with a step budget and
+// checks its output, registers and memory. Failures include a dump of the machine's state and
+// the last executed instructions.
+package simtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/erazemk/sicsim/asm"
+	"github.com/erazemk/sicsim/sim"
+)
+
+// DefaultSteps is the step budget of RunUntilHalt
+const DefaultSteps = 100000
+
+// traceSize is the number of executed instructions shown in failure dumps
+const traceSize = 16
+
+// Program is a program loaded on a machine under test
+type Program struct {
+	M     *sim.Machine
+	Steps int // Step budget of RunUntilHalt, DefaultSteps by default
+
+	t      testing.TB
+	devs   map[byte]*sim.BufferDevice
+	trace  [traceSize]int // Addresses of the last executed instructions, used as a ring buffer
+	traced int            // Number of instructions recorded in trace
+	dumped bool           // The state was dumped by an earlier failure
+}
+
+// LoadAsm assembles the source code src and loads it on a new machine. It fails the test if
+// the code doesn't assemble.
+func LoadAsm(t testing.TB, src string) *Program {
+	t.Helper()
+
+	dir := t.TempDir()
+	path, obj := filepath.Join(dir, "prog.asm"), filepath.Join(dir, "prog.obj")
+
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("simtest: %v", err)
+	}
+
+	if err := asm.AssembleFile(path, obj); err != nil {
+		t.Fatalf("simtest: %v", err)
+	}
+
+	return LoadObj(t, obj)
+}
+
+// LoadObj loads the object file path on a new machine, with the debug info next to it if
+// there is any. It fails the test if the file can't be loaded.
+func LoadObj(t testing.TB, path string) *Program {
+	t.Helper()
+
+	p := &Program{M: new(sim.Machine), Steps: DefaultSteps, t: t, devs: make(map[byte]*sim.BufferDevice)}
+	p.M.New()
+	p.M.SetDeviceDir(t.TempDir())
+	t.Cleanup(func() { p.M.Close() })
+
+	for id := byte(0); id < 3; id++ {
+		p.Device(id)
+	}
+
+	if err := p.M.ParseObjFile(path); err != nil {
+		t.Fatalf("simtest: %v", err)
+	}
+
+	return p
+}
+
+// Device returns device id as an in-memory buffer, attaching an empty one if it isn't attached yet
+func (p *Program) Device(id byte) *sim.BufferDevice {
+	if dev, ok := p.devs[id]; ok {
+		return dev
+	}
+
+	return p.Input(id, "")
+}
+
+// Input attaches an in-memory buffer with input to device id and returns it
+func (p *Program) Input(id byte, input string) *sim.BufferDevice {
+	dev := sim.NewBufferDevice([]byte(input))
+	p.devs[id] = dev
+	p.M.AttachDevice(id, dev)
+	return dev
+}
+
+// Output returns what the program wrote to device id
+func (p *Program) Output(id byte) string {
+	return string(p.Device(id).Output())
+}
+
+// Addr returns the address of label, failing the test if the program has no such label
+func (p *Program) Addr(label string) int {
+	p.t.Helper()
+
+	addr, ok := p.M.Symbols()[label]
+	if !ok {
+		p.fatalf("no label %s", label)
+	}
+
+	return addr
+}
+
+// Step executes up to n instructions, stopping early if the machine halts. It fails the test
+// if an instruction faults.
+func (p *Program) Step(n int) {
+	p.t.Helper()
+
+	for i := 0; i < n && !p.M.Halted(); i++ {
+		p.trace[p.traced%traceSize] = p.M.PC()
+		p.traced++
+
+		if err := p.M.Execute(); err != nil {
+			p.fatalf("%v", err)
+		}
+	}
+}
+
+// RunUntilHalt executes instructions until the machine halts, failing the test if an
+// instruction faults or the machine doesn't halt within p.Steps instructions
+func (p *Program) RunUntilHalt() {
+	p.t.Helper()
+
+	p.Step(p.Steps)
+
+	if !p.M.Halted() {
+		p.fatalf("machine didn't halt within %d instructions", p.Steps)
+	}
+}
+
+// ExpectOutput checks what the program wrote to device id
+func (p *Program) ExpectOutput(id byte, want string) {
+	p.t.Helper()
+
+	if got := p.Output(id); got != want {
+		p.errorf("output of device %02X: got %q, want %q", id, got, want)
+	}
+}
+
+// ExpectReg checks the value of the register with name (A, X, L, B, S, T, F, PC or SW).
+// Values are compared as 24-bit words, so want may be signed or unsigned.
+func (p *Program) ExpectReg(name string, want int) {
+	p.t.Helper()
+
+	no, ok := sim.RegisterNumber(name)
+	if !ok {
+		p.fatalf("invalid register: %s", name)
+	}
+
+	if got, _ := p.M.Reg(no); got&0xFFFFFF != want&0xFFFFFF {
+		p.errorf("register %s: got %s, want %s", strings.ToUpper(name), word(got), word(want))
+	}
+}
+
+// ExpectWord checks the word at label. Values are compared as 24-bit words, so want may be
+// signed or unsigned.
+func (p *Program) ExpectWord(label string, want int) {
+	p.t.Helper()
+	p.expectWord(label, p.Addr(label), want)
+}
+
+// ExpectWordAt checks the word at addr, like ExpectWord
+func (p *Program) ExpectWordAt(addr, want int) {
+	p.t.Helper()
+	p.expectWord(fmt.Sprintf("%06X", addr), addr, want)
+}
+
+func (p *Program) expectWord(name string, addr, want int) {
+	p.t.Helper()
+
+	got, err := p.M.Word(addr)
+	if err != nil {
+		p.fatalf("%v", err)
+	}
+
+	if got&0xFFFFFF != want&0xFFFFFF {
+		p.errorf("word at %s: got %s, want %s", name, word(got), word(want))
+	}
+}
+
+// errorf reports a failure, with the machine's state if it wasn't dumped yet
+func (p *Program) errorf(format string, args ...interface{}) {
+	p.t.Helper()
+	p.t.Error(p.failure(format, args...))
+}
+
+// fatalf reports a failure like errorf and stops the test
+func (p *Program) fatalf(format string, args ...interface{}) {
+	p.t.Helper()
+	p.t.Fatal(p.failure(format, args...))
+}
+
+func (p *Program) failure(format string, args ...interface{}) string {
+	msg := fmt.Sprintf(format, args...)
+
+	if p.dumped {
+		return msg
+	}
+
+	p.dumped = true
+	return msg + "\n" + p.Dump()
+}
+
+// Dump describes the machine's state: its registers, counters, halt reason, the last
+// executed instructions and the output of its devices
+func (p *Program) Dump() string {
+	m := p.M
+	var sb strings.Builder
+
+	sb.WriteString("--- Machine state ---\n")
+	sb.WriteString(m.Regs())
+	fmt.Fprintf(&sb, "\n%d instructions, %d cycles", m.Instructions(), m.Cycles())
+
+	if m.Halted() {
+		fmt.Fprintf(&sb, ", halted: %s", m.HaltReason())
+	}
+
+	start := p.traced - traceSize
+	if start < 0 {
+		start = 0
+	}
+
+	fmt.Fprintf(&sb, "\n--- Last %d instructions ---\n", p.traced-start)
+
+	for i := start; i < p.traced; i++ {
+		addr := p.trace[i%traceSize]
+
+		inst, _, err := m.Disassemble(addr)
+		if err != nil {
+			inst = err.Error()
+		}
+
+		fmt.Fprintf(&sb, "%06X  %-10s %s\n", addr, m.Label(addr), inst)
+	}
+
+	ids := make([]int, 0, len(p.devs))
+	for id := range p.devs {
+		ids = append(ids, int(id))
+	}
+
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if out := p.devs[byte(id)].Output(); len(out) > 0 {
+			fmt.Fprintf(&sb, "--- Output of device %02X ---\n%q\n", id, out)
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// word formats a word in hex and decimal
+func word(val int) string {
+	return fmt.Sprintf("%06X (%d)", val&0xFFFFFF, sim.NewWord(val).Int())
+}
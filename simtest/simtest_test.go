@@ -0,0 +1,81 @@
+package simtest
+
+import (
+	"strings"
+	"testing"
+)
+
+// arith computes the sum and difference of X and Y and prints their sum's last digit
+const arith = `ARITH   START   0
+        LDA     X
+        ADD     Y
+        STA     SUM
+        LDA     X
+        SUB     Y
+        STA     DIFF
+        LDA     SUM
+        ADD     ZERO
+        WD      OUT
+HALT    J       HALT
+X       WORD    3
+Y       WORD    5
+SUM     RESW    1
+DIFF    RESW    1
+ZERO    WORD    48
+OUT     BYTE    X'01'
+        END     ARITH
+`
+
+// dataFirst holds data before its first instruction, so it only runs if the entry point is set
+const dataFirst = `DATA    START   0
+VAL     WORD    7
+RES     RESW    1
+FIRST   LDA     VAL
+        ADD     VAL
+        STA     RES
+HALT    J       HALT
+        END     FIRST
+`
+
+// TestArith checks the results and output of a program assembled from source
+func TestArith(t *testing.T) {
+	p := LoadAsm(t, arith)
+	p.RunUntilHalt()
+
+	p.ExpectWord("SUM", 8)
+	p.ExpectWord("DIFF", -2)
+	p.ExpectWordAt(p.Addr("DIFF"), 0xFFFFFE)
+	p.ExpectReg("A", 56)
+	p.ExpectOutput(1, "8")
+}
+
+// TestRepeatedAssembly checks that each assembled program gets its own entry point
+func TestRepeatedAssembly(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		LoadAsm(t, arith).RunUntilHalt()
+
+		p := LoadAsm(t, dataFirst)
+		p.ExpectReg("PC", p.Addr("FIRST"))
+		p.RunUntilHalt()
+		p.ExpectWord("RES", 14)
+	}
+}
+
+// TestDump checks that the dump shows the registers and the last executed instructions
+func TestDump(t *testing.T) {
+	p := LoadAsm(t, arith)
+	p.Input(0, "unused")
+	p.Step(3)
+
+	dump := p.Dump()
+
+	for _, want := range []string{"3 instructions", "Last 3 instructions", "LDA", "ADD", "STA"} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump doesn't contain %q:\n%s", want, dump)
+		}
+	}
+
+	if p.M.Halted() {
+		t.Error("machine halted after 3 instructions")
+	}
+}